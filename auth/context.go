@@ -14,6 +14,9 @@ type ValidatedJWTPayload struct {
 	Validated bool
 	Token     string
 	Payload   jwt.Payload
+	// Err is the reason validation failed, eg. jwt.ErrTokenExpired. It is nil
+	// when Validated is true.
+	Err error
 }
 
 func ContextWithValidatedJWTPayload(parent context.Context, payload ValidatedJWTPayload) context.Context {
@@ -34,6 +37,65 @@ func GetJWTPayload(ctx context.Context) (ValidatedJWTPayload, bool) {
 	return ValidatedJWTPayload{}, false
 }
 
+// GetUserJWTPayload retrieves the authorized user off the request, returning
+// false if the token was not present, failed validation or is for a service
+// rather than a user.
+func GetUserJWTPayload(ctx context.Context) (jwt.Payload, bool) {
+	v, ok := GetJWTPayload(ctx)
+	if !ok || !v.Payload.IsUser() {
+		return jwt.Payload{}, false
+	}
+
+	return v.Payload, true
+}
+
+// GetServiceJWTPayload retrieves the authorized service off the request,
+// returning false if the token was not present, failed validation or is for a
+// user rather than a service.
+func GetServiceJWTPayload(ctx context.Context) (jwt.Payload, bool) {
+	v, ok := GetJWTPayload(ctx)
+	if !ok || !v.Payload.IsService() {
+		return jwt.Payload{}, false
+	}
+
+	return v.Payload, true
+}
+
+// IsImpersonating returns true if the request's validated token is for a user
+// acting as another user, ie. the real user is not the effective user
+func IsImpersonating(ctx context.Context) bool {
+	payload, ok := GetUserJWTPayload(ctx)
+	return ok && payload.IsImpersonating()
+}
+
+// HasScope returns true if the request's validated token grants 'scope', eg.
+// "surveys:write". Scopes are hierarchical and may use wildcards, see
+// jwt.Payload.HasScope.
+func HasScope(ctx context.Context, scope string) bool {
+	v, ok := GetJWTPayload(ctx)
+	return ok && v.Payload.HasScope(scope)
+}
+
+// HasScopes returns true if the request's validated token grants every scope
+// in 'scopes'
+func HasScopes(ctx context.Context, scopes ...string) bool {
+	v, ok := GetJWTPayload(ctx)
+	return ok && v.Payload.HasScopes(scopes...)
+}
+
+// GetJWTValidationError returns the reason the request's token failed
+// validation, or nil if it was validated successfully or validation did not run.
+// Use errors.Is with the errors exported by the jwt package to decide how to
+// respond, eg. jwt.ErrNoAuthorizationHeader or jwt.ErrTokenExpired.
+func GetJWTValidationError(ctx context.Context) error {
+	payload, ok := ctx.Value(key).(ValidatedJWTPayload)
+	if !ok || payload.Validated {
+		return nil
+	}
+
+	return payload.Err
+}
+
 // ContextHasValidatedJWT returns true if the supplied context contains a
 // validated JWT payload against the same token as that supplied, indicating
 // that the current request's Authorization header has been validated
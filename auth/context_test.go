@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/cultureamp/gocampers/jwt"
@@ -120,3 +121,92 @@ func TestContextHasValidJWTFailsWithTokenMismatch(t *testing.T) {
 
 	assert.False(t, ok)
 }
+
+func TestGetJWTValidationError(t *testing.T) {
+	decodeErr := errors.New("token is expired")
+	ctx := ContextWithValidatedJWTPayload(context.Background(), ValidatedJWTPayload{
+		Validated: false,
+		Token:     "token value",
+		Err:       decodeErr,
+	})
+
+	assert.Equal(t, decodeErr, GetJWTValidationError(ctx))
+}
+
+func TestGetJWTValidationErrorWhenValidOrMissing(t *testing.T) {
+	assert.Nil(t, GetJWTValidationError(context.Background()))
+
+	ctx := ContextWithValidatedJWTPayload(context.Background(), ValidatedJWTPayload{
+		Validated: true,
+		Token:     "token value",
+	})
+	assert.Nil(t, GetJWTValidationError(ctx))
+}
+
+func TestGetUserAndServiceJWTPayload(t *testing.T) {
+	user := jwt.Payload{
+		Principal:     jwt.PrincipalUser,
+		RealUser:      "real user",
+		EffectiveUser: "eff user",
+		Customer:      "customer",
+	}
+	service := jwt.Payload{
+		Principal: jwt.PrincipalService,
+		Service:   "survey-export-worker",
+		Customer:  "customer",
+	}
+
+	ctx := ContextWithValidatedJWTPayload(context.Background(), ValidatedJWTPayload{Validated: true, Payload: user})
+	v, ok := GetUserJWTPayload(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, user, v)
+	_, ok = GetServiceJWTPayload(ctx)
+	assert.False(t, ok)
+
+	ctx = ContextWithValidatedJWTPayload(context.Background(), ValidatedJWTPayload{Validated: true, Payload: service})
+	v, ok = GetServiceJWTPayload(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, service, v)
+	_, ok = GetUserJWTPayload(ctx)
+	assert.False(t, ok)
+
+	ctx = ContextWithValidatedJWTPayload(context.Background(), ValidatedJWTPayload{Validated: false, Payload: service})
+	_, ok = GetServiceJWTPayload(ctx)
+	assert.False(t, ok)
+	_, ok = GetUserJWTPayload(context.Background())
+	assert.False(t, ok)
+}
+
+func TestIsImpersonating(t *testing.T) {
+	assert.False(t, IsImpersonating(context.Background()))
+
+	ctx := ContextWithValidatedJWTPayload(context.Background(), ValidatedJWTPayload{
+		Validated: true,
+		Payload:   jwt.Payload{RealUser: "real user", EffectiveUser: "eff user", Customer: "customer"},
+	})
+	assert.True(t, IsImpersonating(ctx))
+
+	ctx = ContextWithValidatedJWTPayload(context.Background(), ValidatedJWTPayload{
+		Validated: true,
+		Payload:   jwt.Payload{RealUser: "real user", EffectiveUser: "real user", Customer: "customer"},
+	})
+	assert.False(t, IsImpersonating(ctx))
+}
+
+func TestHasScope(t *testing.T) {
+	assert.False(t, HasScope(context.Background(), "surveys:read"))
+
+	ctx := ContextWithValidatedJWTPayload(context.Background(), ValidatedJWTPayload{
+		Validated: true,
+		Payload:   jwt.Payload{Customer: "customer", Scopes: []string{"surveys:*", "reports:read"}},
+	})
+	assert.True(t, HasScope(ctx, "surveys:write"))
+	assert.False(t, HasScope(ctx, "reports:write"))
+	assert.True(t, HasScopes(ctx, "surveys:write", "reports:read"))
+	assert.False(t, HasScopes(ctx, "surveys:write", "reports:write"))
+
+	ctx = ContextWithValidatedJWTPayload(context.Background(), ValidatedJWTPayload{
+		Payload: jwt.Payload{Scopes: []string{"surveys:*"}},
+	})
+	assert.False(t, HasScope(ctx, "surveys:write"))
+}
@@ -6,6 +6,7 @@ require (
 	github.com/cultureamp/gocampers/jwt v0.0.0-20211108051108-8c72c331144a
 	github.com/cultureamp/gocampers/log v0.0.0-20211108034008-936cf72923b9
 	github.com/stretchr/testify v1.7.0
+	google.golang.org/grpc v1.43.0
 )
 
 require (
@@ -16,6 +17,7 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
 	github.com/go-errors/errors v1.4.1 // indirect
+	github.com/golang/protobuf v1.4.3 // indirect
 	github.com/google/uuid v1.2.0 // indirect
 	github.com/gookit/color v1.5.0 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
@@ -26,5 +28,11 @@ require (
 	golang.org/x/net v0.0.0-20210119194325-5f4716e94777 // indirect
 	golang.org/x/sys v0.0.0-20210330210617-4fbd30eecc44 // indirect
 	golang.org/x/text v0.3.5 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+	google.golang.org/protobuf v1.25.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
 )
+
+// auth uses jwt APIs that have not been released yet. Once the jwt module is
+// tagged (jwt/vX.Y.Z), require that version and drop this replace.
+replace github.com/cultureamp/gocampers/jwt => ../jwt
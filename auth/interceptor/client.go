@@ -0,0 +1,87 @@
+package interceptor
+
+import (
+	"context"
+
+	"github.com/cultureamp/gocampers/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TokenSource supplies the token attached to outgoing calls
+type TokenSource interface {
+	// Token returns the token for a call made with 'ctx'. An empty token
+	// sends the call without one.
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc is a TokenSource calling a function for the token, eg. to
+// mint a service token with a jwt.Encoder
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken is a TokenSource attaching the same token to every call
+type StaticToken string
+
+// Token returns the token
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// ForwardToken returns a TokenSource attaching the validated token of the
+// incoming request or call, so a service can call another on behalf of the
+// same user. Calls made without a validated token are sent without one.
+func ForwardToken() TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (string, error) {
+		payload, ok := auth.GetJWTPayload(ctx)
+		if !ok {
+			return "", nil
+		}
+
+		return payload.Token, nil
+	})
+}
+
+// NewUnaryClientInterceptor supplies an interceptor that attaches the token
+// from 'source' to every call as a bearer token in the "authorization" metadata
+func NewUnaryClientInterceptor(source TokenSource) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := withToken(ctx, source)
+		if err != nil {
+			return err
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// NewStreamClientInterceptor supplies an interceptor that attaches the token
+// from 'source' to every streaming call, see NewUnaryClientInterceptor
+func NewStreamClientInterceptor(source TokenSource) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := withToken(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// withToken returns 'ctx' with the token from 'source' in its outgoing
+// metadata, replacing any token already there
+func withToken(ctx context.Context, source TokenSource) (context.Context, error) {
+	token, err := source.Token(ctx)
+	if err != nil || token == "" {
+		return ctx, err
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(AuthorizationMetadataKey, "Bearer "+token)
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
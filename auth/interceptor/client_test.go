@@ -0,0 +1,70 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cultureamp/gocampers/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryClientInterceptorAttachesToken(t *testing.T) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "123")
+
+	md := invokeUnary(t, ctx, StaticToken("jwt-token"))
+	assert.Equal(t, []string{"Bearer jwt-token"}, md.Get(AuthorizationMetadataKey))
+	assert.Equal(t, []string{"123"}, md.Get("x-request-id"))
+}
+
+func TestUnaryClientInterceptorNoToken(t *testing.T) {
+	md := invokeUnary(t, context.Background(), StaticToken(""))
+	assert.Empty(t, md.Get(AuthorizationMetadataKey))
+}
+
+func TestUnaryClientInterceptorTokenError(t *testing.T) {
+	tokenErr := errors.New("no token")
+	source := TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return "", tokenErr
+	})
+
+	err := NewUnaryClientInterceptor(source)(context.Background(), "/test.Service/Unary", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			t.Error("invoker should not be called")
+			return nil
+		})
+	assert.Equal(t, tokenErr, err)
+}
+
+func TestStreamClientInterceptorForwardsToken(t *testing.T) {
+	ctx := auth.ContextWithValidatedJWTPayload(context.Background(), auth.ValidatedJWTPayload{
+		Validated: true,
+		Token:     "incoming-token",
+	})
+
+	var md metadata.MD
+	_, err := NewStreamClientInterceptor(ForwardToken())(ctx, &grpc.StreamDesc{}, nil, "/test.Service/Stream",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			md, _ = metadata.FromOutgoingContext(ctx)
+			return nil, nil
+		})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Bearer incoming-token"}, md.Get(AuthorizationMetadataKey))
+}
+
+// invokeUnary calls the unary client interceptor with a token from 'source',
+// returning the outgoing metadata seen by the invoker
+func invokeUnary(t *testing.T, ctx context.Context, source TokenSource) metadata.MD {
+	var md metadata.MD
+	err := NewUnaryClientInterceptor(source)(ctx, "/test.Service/Unary", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		})
+	require.Nil(t, err)
+
+	return md
+}
@@ -0,0 +1,147 @@
+// Package interceptor provides gRPC interceptors that validate and attach
+// JWTs, the gRPC equivalent of the middleware package.
+package interceptor
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cultureamp/gocampers/auth"
+	"github.com/cultureamp/gocampers/auth/middleware"
+	"github.com/cultureamp/gocampers/jwt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthorizationMetadataKey is the metadata key holding the bearer token. gRPC
+// metadata keys are always lower case.
+const AuthorizationMetadataKey = "authorization"
+
+// ServerOption configures the server interceptors
+type ServerOption func(*serverConfig)
+
+type serverConfig struct {
+	validator        middleware.TokenValidator
+	validatorOptions []middleware.Option
+	required         bool
+	publicMethods    map[string]bool
+}
+
+// RequireAuthentication rejects calls without a valid token with
+// codes.Unauthenticated, rather than leaving the decision to the handler
+func RequireAuthentication() ServerOption {
+	return func(c *serverConfig) {
+		c.required = true
+	}
+}
+
+// WithPublicMethods lets calls to 'fullMethods', eg.
+// "/grpc.health.v1.Health/Check", through without a valid token when
+// RequireAuthentication is set. Their token is still validated if present.
+func WithPublicMethods(fullMethods ...string) ServerOption {
+	return func(c *serverConfig) {
+		for _, method := range fullMethods {
+			c.publicMethods[method] = true
+		}
+	}
+}
+
+// WithValidatorOptions configures how tokens are validated with 'opts', eg.
+// middleware.WithAuditor to change where impersonated calls are audited
+func WithValidatorOptions(opts ...middleware.Option) ServerOption {
+	return func(c *serverConfig) {
+		c.validatorOptions = append(c.validatorOptions, opts...)
+	}
+}
+
+// NewUnaryServerInterceptor supplies an interceptor that will decode a JWT
+// present in the "authorization" metadata, placing the result of this
+// validation on the context in the same way as
+// middleware.NewJWTValidationMiddleware. Handlers read it with
+// auth.GetJWTPayload and auth.GetJWTValidationError.
+func NewUnaryServerInterceptor(decoder middleware.Decoder, opts ...ServerOption) grpc.UnaryServerInterceptor {
+	config := newServerConfig(decoder, opts)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := config.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// NewStreamServerInterceptor supplies an interceptor that validates the JWT of
+// a streaming call, see NewUnaryServerInterceptor
+func NewStreamServerInterceptor(decoder middleware.Decoder, opts ...ServerOption) grpc.StreamServerInterceptor {
+	config := newServerConfig(decoder, opts)
+
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := config.authenticate(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func newServerConfig(decoder middleware.Decoder, opts []ServerOption) serverConfig {
+	config := serverConfig{
+		publicMethods: map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	config.validator = middleware.NewTokenValidator(decoder, config.validatorOptions...)
+
+	return config
+}
+
+// authenticate validates the token in the incoming metadata of 'ctx',
+// returning the context holding the result. It returns a codes.Unauthenticated
+// error if the token is required and not valid. The reason is only logged, so
+// callers can't learn how validation works.
+func (c serverConfig) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	v := c.validateMetadata(ctx, fullMethod)
+	ctx = auth.ContextWithValidatedJWTPayload(ctx, v)
+
+	if c.required && !v.Validated && !c.publicMethods[fullMethod] {
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing token")
+	}
+
+	return ctx, nil
+}
+
+// validateMetadata decodes the bearer token in the incoming metadata of 'ctx'.
+// No decoded JWT details are returned if it fails validation.
+func (c serverConfig) validateMetadata(ctx context.Context, fullMethod string) auth.ValidatedJWTPayload {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(AuthorizationMetadataKey); len(values) > 0 {
+			header = values[0]
+		}
+	}
+
+	token, err := jwt.BearerToken(header)
+	if err != nil {
+		return auth.ValidatedJWTPayload{Err: err}
+	}
+
+	// gRPC calls are HTTP/2 POST requests to the full method
+	return c.validator.Validate(ctx, token, http.MethodPost, fullMethod)
+}
+
+// contextStream is a grpc.ServerStream with the context holding the validated
+// JWT payload
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
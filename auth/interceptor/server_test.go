@@ -0,0 +1,198 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cultureamp/gocampers/auth"
+	"github.com/cultureamp/gocampers/auth/middleware"
+	"github.com/cultureamp/gocampers/jwt"
+	"github.com/cultureamp/gocampers/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptorValidates(t *testing.T) {
+	payloadResult := jwt.Payload{
+		RealUser:      "real",
+		EffectiveUser: "effective-user",
+		Customer:      "customer",
+	}
+	decoder := &testDecoder{}
+	decoder.On("Decode", "jwt-token").Return(payloadResult, nil)
+
+	ctx := incomingContext("Bearer jwt-token")
+	var actualContext context.Context
+	_, err := NewUnaryServerInterceptor(decoder)(ctx, nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		actualContext = ctx
+		return nil, nil
+	})
+	require.Nil(t, err)
+
+	v, ok := auth.GetJWTPayload(actualContext)
+	assert.True(t, ok)
+	assert.Equal(t, "jwt-token", v.Token)
+	assert.Equal(t, payloadResult, v.Payload)
+	decoder.AssertExpectations(t)
+}
+
+func TestUnaryServerInterceptorInvalidToken(t *testing.T) {
+	cases := map[string]error{
+		"":                   jwt.ErrNoAuthorizationHeader,
+		"Basic dXNlcjpwYXNz": jwt.ErrNotBearer,
+		"Bearer bad-token":   jwt.ErrTokenExpired,
+	}
+
+	for headerValue, expectedErr := range cases {
+		t.Run(headerValue, func(t *testing.T) {
+			decoder := &testDecoder{}
+			decoder.On("Decode", "bad-token").Return(jwt.Payload{}, jwt.ErrTokenExpired)
+
+			called := false
+			var actualContext context.Context
+			_, err := NewUnaryServerInterceptor(decoder)(incomingContext(headerValue), nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				actualContext = ctx
+				return nil, nil
+			})
+			require.Nil(t, err)
+			assert.True(t, called)
+
+			_, ok := auth.GetJWTPayload(actualContext)
+			assert.False(t, ok)
+			assert.True(t, errors.Is(auth.GetJWTValidationError(actualContext), expectedErr))
+		})
+	}
+}
+
+func TestUnaryServerInterceptorRequireAuthentication(t *testing.T) {
+	decoder := &testDecoder{}
+	decoder.On("Decode", "bad-token").Return(jwt.Payload{}, jwt.ErrTokenExpired)
+
+	interceptor := NewUnaryServerInterceptor(decoder,
+		RequireAuthentication(),
+		WithPublicMethods("/grpc.health.v1.Health/Check"),
+	)
+
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+
+	_, err := interceptor(incomingContext("Bearer bad-token"), nil, unaryInfo, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.NotContains(t, status.Convert(err).Message(), jwt.ErrTokenExpired.Error())
+	assert.False(t, called)
+
+	_, err = interceptor(incomingContext(""), nil, unaryInfo, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.False(t, called)
+
+	_, err = interceptor(incomingContext(""), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+	assert.Nil(t, err)
+	assert.True(t, called)
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	decoder := &testDecoder{}
+	decoder.On("Decode", "jwt-token").Return(jwt.Payload{Customer: "customer"}, nil)
+
+	stream := testServerStream{ctx: incomingContext("Bearer jwt-token")}
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+
+	var actualContext context.Context
+	err := NewStreamServerInterceptor(decoder, RequireAuthentication())(nil, stream, info, func(srv interface{}, stream grpc.ServerStream) error {
+		actualContext = stream.Context()
+		return nil
+	})
+	require.Nil(t, err)
+
+	v, ok := auth.GetJWTPayload(actualContext)
+	assert.True(t, ok)
+	assert.Equal(t, "customer", v.Payload.Customer)
+
+	stream = testServerStream{ctx: incomingContext("")}
+	err = NewStreamServerInterceptor(decoder, RequireAuthentication())(nil, stream, info, func(srv interface{}, stream grpc.ServerStream) error {
+		t.Error("handler should not be called")
+		return nil
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestServerInterceptorAuditsImpersonation(t *testing.T) {
+	decoder := &testDecoder{}
+	decoder.On("Decode", "impersonated").Return(jwt.Payload{
+		Customer:      "customer",
+		RealUser:      "support-person",
+		EffectiveUser: "end-user",
+	}, nil)
+	audits := &testAuditor{}
+
+	interceptor := NewUnaryServerInterceptor(decoder, WithValidatorOptions(middleware.WithAuditor(audits)))
+	_, err := interceptor(incomingContext("Bearer impersonated"), nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	require.Nil(t, err)
+
+	require.Len(t, audits.events, 1)
+	assert.Equal(t, "impersonated_request", audits.events[0].event)
+	assert.Equal(t, log.Fields{
+		"account_id":        "customer",
+		"real_user_id":      "support-person",
+		"effective_user_id": "end-user",
+		"method":            "POST",
+		"path":              "/test.Service/Unary",
+	}, audits.events[0].fields)
+}
+
+// testAuditor records the audit events written during a test
+type testAuditor struct {
+	events []auditEvent
+}
+
+type auditEvent struct {
+	event  string
+	fields log.Fields
+}
+
+func (a *testAuditor) Audit(event string, fields ...log.Fields) string {
+	a.events = append(a.events, auditEvent{event: event, fields: log.Fields{}.Merge(fields...)})
+	return event
+}
+
+var unaryInfo = &grpc.UnaryServerInfo{FullMethod: "/test.Service/Unary"}
+
+// incomingContext returns a context with 'header' as the incoming
+// authorization metadata, or no metadata if it is empty
+func incomingContext(header string) context.Context {
+	if header == "" {
+		return context.Background()
+	}
+
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationMetadataKey, header))
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+type testDecoder struct {
+	mock.Mock
+}
+
+func (d *testDecoder) Decode(tokenString string) (jwt.Payload, error) {
+	args := d.Called(tokenString)
+	return args.Get(0).(jwt.Payload), args.Error(1)
+}
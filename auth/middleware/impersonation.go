@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cultureamp/gocampers/auth"
+	"github.com/cultureamp/gocampers/jwt"
+	"github.com/cultureamp/gocampers/log"
+)
+
+// DefaultImpersonationRolesClaim is the claim holding the roles of the real
+// user when an ImpersonationPolicy does not set RolesClaim
+const DefaultImpersonationRolesClaim = "realUserRoles"
+
+// ImpersonationPolicy limits what a user acting as another user can do. The
+// zero value allows everything.
+type ImpersonationPolicy struct {
+	// AllowedRoles are the roles allowed to impersonate. The real user must
+	// have at least one of them. Empty allows any user.
+	AllowedRoles []string
+	// RolesClaim is the claim holding the roles of the real user, or
+	// DefaultImpersonationRolesClaim if empty
+	RolesClaim string
+	// MaxDuration is how long after it started an impersonation session can be
+	// used, see jwt.Payload.ImpersonationStartedAt. Zero means no limit.
+	MaxDuration time.Duration
+	// ReadOnly only allows safe requests (GET, HEAD and OPTIONS)
+	ReadOnly bool
+}
+
+// NewImpersonationMiddleware supplies middleware that rejects requests where a
+// user is acting as another user against 'policy', responding with 403
+// Forbidden. It must run after the middleware from NewJWTValidationMiddleware.
+// Requests that are not impersonated are passed through untouched. Use
+// WithAuditor and WithImpersonationClock to change where denials are audited
+// and the time MaxDuration is checked against.
+func NewImpersonationMiddleware(policy ImpersonationPolicy, opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			payload, ok := auth.GetUserJWTPayload(ctx)
+			if !ok || !payload.IsImpersonating() {
+				next.ServeHTTP(resp, req)
+				return
+			}
+
+			if reason := policy.check(req, payload, o.now()); reason != "" {
+				auditImpersonation(o.auditorFor(ctx), "impersonation_denied", req.Method, req.URL.Path, payload, log.Fields{"reason": reason})
+				http.Error(resp, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(resp, req)
+		})
+	}
+}
+
+// check returns why the impersonated request breaks the policy, or an empty
+// string if it is allowed
+func (p ImpersonationPolicy) check(req *http.Request, payload jwt.Payload, now time.Time) string {
+	if len(p.AllowedRoles) > 0 && !p.hasAllowedRole(payload) {
+		return "role_not_allowed"
+	}
+
+	if p.MaxDuration > 0 {
+		started, ok := payload.ImpersonationStartedAt()
+		if !ok || now.Sub(started) > p.MaxDuration {
+			return "session_expired"
+		}
+	}
+
+	if p.ReadOnly {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			return "read_only"
+		}
+	}
+
+	return ""
+}
+
+func (p ImpersonationPolicy) hasAllowedRole(payload jwt.Payload) bool {
+	claim := p.RolesClaim
+	if claim == "" {
+		claim = DefaultImpersonationRolesClaim
+	}
+
+	roles, _ := payload.Claims.Strings(claim)
+	for _, role := range roles {
+		for _, allowed := range p.AllowedRoles {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// auditImpersonation writes an audit event recording both the real and
+// effective user of an impersonated request
+func auditImpersonation(auditor Auditor, event string, method string, path string, payload jwt.Payload, fields ...log.Fields) {
+	properties := log.Fields{
+		"account_id":        payload.Customer,
+		"real_user_id":      payload.RealUser,
+		"effective_user_id": payload.EffectiveUser,
+		"method":            method,
+		"path":              path,
+	}
+
+	auditor.Audit(event, properties.Merge(fields...))
+}
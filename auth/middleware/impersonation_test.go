@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cultureamp/gocampers/auth"
+	"github.com/cultureamp/gocampers/jwt"
+	"github.com/cultureamp/gocampers/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type auditEvent struct {
+	event  string
+	fields log.Fields
+}
+
+type testAuditor struct {
+	mu     sync.Mutex
+	events []auditEvent
+}
+
+func (a *testAuditor) Audit(event string, fields ...log.Fields) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, auditEvent{event: event, fields: log.Fields{}.Merge(fields...)})
+	return event
+}
+
+func impersonatedPayload(claims jwt.Claims) jwt.Payload {
+	return jwt.Payload{
+		Customer:      "customer",
+		RealUser:      "support-person",
+		EffectiveUser: "end-user",
+		Claims:        claims,
+	}
+}
+
+func requestWithPayload(method string, payload jwt.Payload) *http.Request {
+	r := httptest.NewRequest(method, "/surveys", nil)
+	ctx := auth.ContextWithValidatedJWTPayload(r.Context(), auth.ValidatedJWTPayload{Validated: true, Payload: payload})
+	return r.WithContext(ctx)
+}
+
+func TestValidationAuditsImpersonation(t *testing.T) {
+	audits := &testAuditor{}
+	decoder := &testDecoder{}
+	decoder.On("Decode", "impersonated").Return(impersonatedPayload(nil), nil)
+	decoder.On("Decode", "direct").Return(jwt.Payload{RealUser: "user", EffectiveUser: "user", Customer: "customer"}, nil)
+	sut := NewJWTValidationMiddleware(decoder, WithAuditor(audits))(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest("POST", "/surveys", nil)
+	r.Header.Set("Authorization", "Bearer direct")
+	sut.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Empty(t, audits.events)
+
+	r.Header.Set("Authorization", "Bearer impersonated")
+	sut.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, []auditEvent{{
+		event: "impersonated_request",
+		fields: log.Fields{
+			"account_id":        "customer",
+			"real_user_id":      "support-person",
+			"effective_user_id": "end-user",
+			"method":            "POST",
+			"path":              "/surveys",
+		},
+	}}, audits.events)
+}
+
+func TestImpersonationPolicy(t *testing.T) {
+	now := time.Date(2021, 11, 8, 9, 0, 0, 0, time.UTC)
+	clock := jwt.ClockFunc(func() time.Time { return now })
+	started := float64(now.Add(-2 * time.Hour).Unix())
+	cases := map[string]struct {
+		policy   ImpersonationPolicy
+		method   string
+		payload  jwt.Payload
+		expected int
+		reason   string
+	}{
+		"not impersonating": {
+			ImpersonationPolicy{ReadOnly: true}, "POST",
+			jwt.Payload{RealUser: "user", EffectiveUser: "user"}, http.StatusOK, "",
+		},
+		"allowed role": {
+			ImpersonationPolicy{AllowedRoles: []string{"support"}}, "GET",
+			impersonatedPayload(jwt.Claims{"realUserRoles": []interface{}{"support"}}), http.StatusOK, "",
+		},
+		"missing role": {
+			ImpersonationPolicy{AllowedRoles: []string{"support"}}, "GET",
+			impersonatedPayload(jwt.Claims{"realUserRoles": []interface{}{"admin"}}), http.StatusForbidden, "role_not_allowed",
+		},
+		"custom roles claim": {
+			ImpersonationPolicy{AllowedRoles: []string{"support"}, RolesClaim: "roles"}, "GET",
+			impersonatedPayload(jwt.Claims{"roles": []interface{}{"support"}}), http.StatusOK, "",
+		},
+		"within duration": {
+			ImpersonationPolicy{MaxDuration: 4 * time.Hour}, "GET",
+			impersonatedPayload(jwt.Claims{jwt.ImpersonationStartedAtClaim: started}), http.StatusOK, "",
+		},
+		"session expired": {
+			ImpersonationPolicy{MaxDuration: time.Hour}, "GET",
+			impersonatedPayload(jwt.Claims{jwt.ImpersonationStartedAtClaim: started}), http.StatusForbidden, "session_expired",
+		},
+		"unknown start": {
+			ImpersonationPolicy{MaxDuration: time.Hour}, "GET",
+			impersonatedPayload(nil), http.StatusForbidden, "session_expired",
+		},
+		"read only allows GET": {
+			ImpersonationPolicy{ReadOnly: true}, "GET",
+			impersonatedPayload(nil), http.StatusOK, "",
+		},
+		"read only rejects POST": {
+			ImpersonationPolicy{ReadOnly: true}, "POST",
+			impersonatedPayload(nil), http.StatusForbidden, "read_only",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			audits := &testAuditor{}
+			called := false
+			sut := NewImpersonationMiddleware(c.policy, WithAuditor(audits), WithImpersonationClock(clock))(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+
+			rw := httptest.NewRecorder()
+			sut.ServeHTTP(rw, requestWithPayload(c.method, c.payload))
+
+			assert.Equal(t, c.expected, rw.Code)
+			assert.Equal(t, c.expected == http.StatusOK, called)
+			if c.reason == "" {
+				assert.Empty(t, audits.events)
+			} else {
+				assert.Len(t, audits.events, 1)
+				assert.Equal(t, "impersonation_denied", audits.events[0].event)
+				assert.Equal(t, c.reason, audits.events[0].fields["reason"])
+				assert.Equal(t, "support-person", audits.events[0].fields["real_user_id"])
+				assert.Equal(t, "end-user", audits.events[0].fields["effective_user_id"])
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/cultureamp/gocampers/auth"
 	"github.com/cultureamp/gocampers/log"
 	"net/http"
-	"strings"
 
 	"github.com/cultureamp/gocampers/jwt"
 )
@@ -18,13 +17,16 @@ type Decoder interface {
 }
 
 type jwtValidationMiddleware struct {
-	next    http.Handler
-	decoder Decoder
+	next      http.Handler
+	validator TokenValidator
+	extractor jwt.TokenExtractor
 }
 
 // NewJWTValidationMiddleware supplies middleware that will decode a JWT present
 // in the Authorization header, placing the result of this validation on the
-// context.
+// context. Use WithTokenExtractors to read the token from elsewhere, eg. a
+// session cookie as well as the header. When validation fails the reason is
+// available from auth.GetJWTValidationError.
 //
 // It does *NOT* otherwise modify the request: taking action as a result of
 // failed validation is delegated to the handler for the current route (which
@@ -33,11 +35,19 @@ type jwtValidationMiddleware struct {
 //
 // Details of the decoded JWT is only placed in the context if validation
 // succeeds.
-func NewJWTValidationMiddleware(decoder Decoder) func(http.Handler) http.Handler {
+func NewJWTValidationMiddleware(decoder Decoder, opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions(opts)
+	var extractor jwt.TokenExtractor = jwt.NewHeaderExtractor(jwt.AuthorizationHeader)
+	if len(o.extractors) > 0 {
+		extractor = jwt.NewExtractorChain(o.extractors...)
+	}
+	validator := NewTokenValidator(decoder, opts...)
+
 	return func(next http.Handler) http.Handler {
 		v := jwtValidationMiddleware{
-			next:    next,
-			decoder: decoder,
+			next:      next,
+			validator: validator,
+			extractor: extractor,
 		}
 
 		return v
@@ -46,40 +56,59 @@ func NewJWTValidationMiddleware(decoder Decoder) func(http.Handler) http.Handler
 
 func (m jwtValidationMiddleware) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
-	token := getBearerToken(req.Header.Get("Authorization"))
 
-	v := m.validateToken(ctx, token)
+	var v auth.ValidatedJWTPayload
+	token, err := m.extractor.Extract(req)
+	if err != nil {
+		v = auth.ValidatedJWTPayload{Err: err}
+	} else {
+		v = m.validator.Validate(ctx, token, req.Method, req.URL.Path)
+	}
 	ctx = auth.ContextWithValidatedJWTPayload(ctx, v)
 
 	m.next.ServeHTTP(resp, req.WithContext(ctx))
 }
 
-// validateToken uses the decoder to validate the supplied token, returning the
+// TokenValidator validates bearer tokens for NewJWTValidationMiddleware and
+// the gRPC interceptors in auth/interceptor, so tokens are logged and audited
+// the same way whichever transport carries them
+type TokenValidator struct {
+	decoder Decoder
+	options options
+}
+
+// NewTokenValidator creates a TokenValidator decoding tokens with 'decoder'.
+// Use WithAuditor to change where impersonated requests are audited.
+func NewTokenValidator(decoder Decoder, opts ...Option) TokenValidator {
+	return TokenValidator{
+		decoder: decoder,
+		options: newOptions(opts),
+	}
+}
+
+// Validate uses the decoder to validate the supplied token, returning the
 // resulting payload. No decoded JWT details are returned if it fails
-// validation.
-func (m jwtValidationMiddleware) validateToken(ctx context.Context, token string) auth.ValidatedJWTPayload {
-	v := auth.ValidatedJWTPayload{
+// validation. The failure is logged, and a request where a user is acting as
+// another user is audited, identified by 'method' and 'path', eg. the HTTP
+// method and URL path, or "POST" and the full gRPC method.
+func (v TokenValidator) Validate(ctx context.Context, token string, method string, path string) auth.ValidatedJWTPayload {
+	result := auth.ValidatedJWTPayload{
 		Token: token,
 	}
 
-	payload, err := m.decoder.Decode(token)
+	payload, err := v.decoder.Decode(token)
 	if err == nil {
-		v.Validated = true
-		v.Payload = payload
+		result.Validated = true
+		result.Payload = payload
 	} else {
+		result.Err = err
 		logger := log.NewFromCtx(ctx)
 		logger.Error("jwt_validation_failed", err)
 	}
 
-	return v
-}
-
-// getBearerToken strips the required "Bearer " prefix from an Authorization header
-// value, returning an empty string if the prefix is not present.
-func getBearerToken(header string) string {
-	if !strings.HasPrefix(header, "Bearer ") {
-		return ""
+	if result.Validated && result.Payload.IsImpersonating() {
+		auditImpersonation(v.options.auditorFor(ctx), "impersonated_request", method, path, result.Payload)
 	}
 
-	return header[7:]
+	return result
 }
@@ -50,6 +50,36 @@ func TestMiddlewareValidationOccurs(t *testing.T) {
 	decoder.AssertExpectations(t)
 }
 
+func TestMiddlewareMissingOrNonBearerHeader(t *testing.T) {
+	cases := map[string]error{
+		"":                   jwt.ErrNoAuthorizationHeader,
+		"Basic dXNlcjpwYXNz": jwt.ErrNotBearer,
+	}
+
+	for headerValue, expectedErr := range cases {
+		t.Run(headerValue, func(t *testing.T) {
+			decoder := &testDecoder{}
+
+			var actualContext context.Context
+			nextHandler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				actualContext = r.Context()
+			})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			if headerValue != "" {
+				r.Header.Set("Authorization", headerValue)
+			}
+
+			NewJWTValidationMiddleware(decoder)(nextHandler).ServeHTTP(httptest.NewRecorder(), r)
+
+			_, ok := auth.GetJWTPayload(actualContext)
+			assert.False(t, ok)
+			assert.True(t, errors.Is(auth.GetJWTValidationError(actualContext), expectedErr))
+			decoder.AssertNotCalled(t, "Decode", mock.Anything)
+		})
+	}
+}
+
 func TestBearerValidateTokenSucceeds(t *testing.T) {
 	token := "jwt-token-supplied"
 
@@ -61,11 +91,9 @@ func TestBearerValidateTokenSucceeds(t *testing.T) {
 	decoder := &testDecoder{}
 	decoder.On("Decode", token).Return(payloadResult, nil)
 
-	sut := jwtValidationMiddleware{
-		decoder: decoder,
-	}
+	sut := NewTokenValidator(decoder)
 
-	payload := sut.validateToken(context.Background(), token)
+	payload := sut.Validate(context.Background(), token, http.MethodGet, "/")
 
 	expectedPayload := auth.ValidatedJWTPayload{
 		Validated: true,
@@ -86,16 +114,15 @@ func TestBearerValidateTokenFails(t *testing.T) {
 	decoder := &testDecoder{}
 	decoder.On("Decode", token).Return(payloadResult, decodeError)
 
-	sut := jwtValidationMiddleware{
-		decoder: decoder,
-	}
+	sut := NewTokenValidator(decoder)
 
-	payload := sut.validateToken(context.Background(), token)
+	payload := sut.Validate(context.Background(), token, http.MethodGet, "/")
 
 	expectedPayload := auth.ValidatedJWTPayload{
 		Validated: false,
 		Token:     token,
 		Payload:   payloadResult,
+		Err:       decodeError,
 	}
 
 	assert.Equal(t, expectedPayload, payload)
@@ -103,25 +130,81 @@ func TestBearerValidateTokenFails(t *testing.T) {
 }
 
 func TestBearerTokenPresent(t *testing.T) {
-	headerValue := "Bearer foo"
-	token := getBearerToken(headerValue)
+	cases := []string{"Bearer foo", "bearer foo", "BEARER foo", "Bearer  foo"}
+
+	for _, headerValue := range cases {
+		t.Run(headerValue, func(t *testing.T) {
+			decoder := &testDecoder{}
+			decoder.On("Decode", "foo").Return(jwt.Payload{}, nil)
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", headerValue)
 
-	expected := "foo"
-	assert.Equal(t, expected, token)
+			v := validateRequest(r, decoder)
+			assert.True(t, v.Validated)
+			assert.Equal(t, "foo", v.Token)
+			decoder.AssertExpectations(t)
+		})
+	}
 }
 
 func TestBearerTokenInvalidOrNotPresent(t *testing.T) {
-	cases := []string{"", "BearerFoo", "ABC", "Bearer", "Bearer ", "bearer foo"}
+	cases := []string{"BearerFoo", "ABC", "Bearer", "Bearer ", "Bearer foo bar"}
 
 	for _, headerValue := range cases {
 		t.Run(headerValue, func(t *testing.T) {
-			token := getBearerToken(headerValue)
-			expected := ""
-			assert.Equal(t, expected, token)
+			decoder := &testDecoder{}
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", headerValue)
+
+			v := validateRequest(r, decoder)
+			assert.False(t, v.Validated)
+			assert.True(t, errors.Is(v.Err, jwt.ErrNotBearer))
+			decoder.AssertNotCalled(t, "Decode", mock.Anything)
 		})
 	}
 }
 
+func TestMiddlewareTokenExtractors(t *testing.T) {
+	decoder := &testDecoder{}
+	decoder.On("Decode", "from-cookie").Return(jwt.Payload{}, nil)
+	decoder.On("Decode", "from-custom-header").Return(jwt.Payload{}, nil)
+
+	extractors := []jwt.TokenExtractor{
+		jwt.NewHeaderExtractor(BFFCustomAuthHeader),
+		jwt.NewCookieExtractor("session"),
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	v := validateRequest(r, decoder, extractors...)
+	assert.False(t, v.Validated)
+	assert.True(t, errors.Is(v.Err, jwt.ErrNoAuthorizationHeader))
+
+	r.AddCookie(&http.Cookie{Name: "session", Value: "from-cookie"})
+	v = validateRequest(r, decoder, extractors...)
+	assert.True(t, v.Validated)
+	assert.Equal(t, "from-cookie", v.Token)
+
+	r.Header.Set(BFFCustomAuthHeader, "Bearer from-custom-header")
+	v = validateRequest(r, decoder, extractors...)
+	assert.True(t, v.Validated)
+	assert.Equal(t, "from-custom-header", v.Token)
+}
+
+// validateRequest runs the validation middleware over 'r', returning the
+// validated payload and any validation error placed on the context
+func validateRequest(r *http.Request, decoder Decoder, extractors ...jwt.TokenExtractor) auth.ValidatedJWTPayload {
+	var v auth.ValidatedJWTPayload
+	nextHandler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		v, _ = auth.GetJWTPayload(r.Context())
+		v.Err = auth.GetJWTValidationError(r.Context())
+	})
+
+	NewJWTValidationMiddleware(decoder, WithTokenExtractors(extractors...))(nextHandler).ServeHTTP(httptest.NewRecorder(), r)
+	return v
+}
+
 type testDecoder struct {
 	mock.Mock
 }
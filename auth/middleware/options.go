@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/cultureamp/gocampers/jwt"
+	"github.com/cultureamp/gocampers/log"
+)
+
+// Auditor writes audit events, eg. a log.Logger
+type Auditor interface {
+	Audit(event string, fields ...log.Fields) string
+}
+
+// Option configures the middleware and TokenValidator in this package
+type Option func(*options)
+
+type options struct {
+	extractors []jwt.TokenExtractor
+	auditor    Auditor
+	clock      jwt.Clock
+}
+
+// WithTokenExtractors reads the token with the first of 'extractors' to find
+// one, instead of from the Authorization header, eg. to accept a session
+// cookie as well as the header
+func WithTokenExtractors(extractors ...jwt.TokenExtractor) Option {
+	return func(o *options) {
+		o.extractors = extractors
+	}
+}
+
+// WithAuditor writes the audit events of impersonated requests to 'auditor',
+// instead of the logger of the request's context
+func WithAuditor(auditor Auditor) Option {
+	return func(o *options) {
+		o.auditor = auditor
+	}
+}
+
+// WithImpersonationClock sets the Clock impersonation sessions are timed
+// against by NewImpersonationMiddleware, instead of the system clock
+func WithImpersonationClock(clock jwt.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// auditorFor returns the Auditor for a request with 'ctx'
+func (o options) auditorFor(ctx context.Context) Auditor {
+	if o.auditor == nil {
+		return log.NewFromCtx(ctx)
+	}
+
+	return o.auditor
+}
+
+func (o options) now() time.Time {
+	if o.clock == nil {
+		return time.Now()
+	}
+
+	return o.clock.Now()
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cultureamp/gocampers/auth"
+)
+
+// problemContentType is the media type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// problemDetails is an RFC 7807 problem details response body
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// MissingScopes are the required scopes the token does not grant
+	MissingScopes []string `json:"missingScopes,omitempty"`
+}
+
+// RequireScopes supplies middleware that only passes on requests whose
+// validated token grants every scope in 'scopes', see jwt.Payload.HasScope for
+// how scopes match. Requests without a validated token get 401 Unauthorized
+// and those missing a scope get 403 Forbidden, both with a problem details
+// body. It must run after the middleware from NewJWTValidationMiddleware.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			v, ok := auth.GetJWTPayload(req.Context())
+			if !ok {
+				resp.Header().Set("WWW-Authenticate", "Bearer")
+				writeProblem(resp, problemDetails{
+					Type:   "about:blank",
+					Title:  http.StatusText(http.StatusUnauthorized),
+					Status: http.StatusUnauthorized,
+					Detail: "a valid token is required",
+				})
+				return
+			}
+
+			var missing []string
+			for _, scope := range scopes {
+				if !v.Payload.HasScope(scope) {
+					missing = append(missing, scope)
+				}
+			}
+			if len(missing) > 0 {
+				writeProblem(resp, problemDetails{
+					Type:          "about:blank",
+					Title:         http.StatusText(http.StatusForbidden),
+					Status:        http.StatusForbidden,
+					Detail:        "the token does not grant the required scopes",
+					MissingScopes: missing,
+				})
+				return
+			}
+
+			next.ServeHTTP(resp, req)
+		})
+	}
+}
+
+func writeProblem(resp http.ResponseWriter, problem problemDetails) {
+	resp.Header().Set("Content-Type", problemContentType)
+	resp.WriteHeader(problem.Status)
+	_ = json.NewEncoder(resp).Encode(problem)
+}
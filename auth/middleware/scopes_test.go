@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cultureamp/gocampers/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireScopes(t *testing.T) {
+	handler := RequireScopes("surveys:write", "reports:read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	payload := jwt.Payload{Customer: "customer", RealUser: "user", EffectiveUser: "user"}
+
+	payload.Scopes = []string{"surveys:*", "reports"}
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, requestWithPayload(http.MethodPost, payload))
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	payload.Scopes = []string{"surveys:read", "reports:read"}
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, requestWithPayload(http.MethodPost, payload))
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"))
+
+	var problem problemDetails
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, http.StatusForbidden, problem.Status)
+	assert.Equal(t, "Forbidden", problem.Title)
+	assert.Equal(t, []string{"surveys:write"}, problem.MissingScopes)
+}
+
+func TestRequireScopesWithoutToken(t *testing.T) {
+	called := false
+	handler := RequireScopes("surveys:write")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/surveys", nil))
+	assert.False(t, called)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Equal(t, "Bearer", resp.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"))
+}
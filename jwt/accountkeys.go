@@ -0,0 +1,345 @@
+package jwt
+
+import (
+	"container/list"
+	"crypto"
+	"fmt"
+	"sync"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-errors/errors"
+)
+
+const (
+	defaultAccountKeyCacheTTL      = 5 * time.Minute
+	defaultAccountKeyCacheSize     = 1000
+	defaultAccountKeyRetryInterval = 5 * time.Second
+)
+
+// AccountKeyProvider supplies the signing keys of individual accounts, so a
+// compromised key only affects the account it belongs to. Accounts without a
+// key of their own use the global key of the Encoder or Decoder.
+type AccountKeyProvider interface {
+	// SigningKey returns the Signer for tokens of the account 'accountID',
+	// and the "kid" identifying its key. It returns an error wrapping
+	// ErrKeyNotFound if the account has no key of its own.
+	SigningKey(accountID string) (kid string, signer Signer, err error)
+	// VerificationKey returns the public key of the account 'accountID', and
+	// the "kid" identifying it. It returns an error wrapping ErrKeyNotFound if
+	// the account has no key of its own.
+	VerificationKey(accountID string) (kid string, key crypto.PublicKey, err error)
+}
+
+// AccountKeys caches the keys supplied by an AccountKeyProvider, so the
+// provider is not called for every token. Keys are cached for 5 minutes by
+// default. It is safe for concurrent use.
+//
+// The account of a token is looked up before its signature is checked, so
+// failed lookups are cached too, separately from the keys so lookups of made
+// up accounts can't evict real ones: an account without a key is not looked up
+// again until the cache TTL has passed, and one whose lookup failed with any
+// other error not for 5 seconds by default. Accounts with a cached key are
+// never held back by the failed lookups of others.
+type AccountKeys struct {
+	provider AccountKeyProvider
+	ttl      time.Duration
+	size     int
+	retry    time.Duration
+	clock    Clock
+
+	signers   *accountKeyCache // by account id
+	verifiers *accountKeyCache // by account id
+	failed    *accountKeyCache // errors by account id
+}
+
+// AccountKeysOption configures AccountKeys
+type AccountKeysOption func(*AccountKeys)
+
+// WithAccountKeyCacheTTL sets how long keys are cached for. A rotated or
+// revoked account key is picked up once its cache entry expires.
+func WithAccountKeyCacheTTL(ttl time.Duration) AccountKeysOption {
+	return func(k *AccountKeys) {
+		k.ttl = ttl
+	}
+}
+
+// WithAccountKeyCacheSize sets how many signing keys, verification keys and
+// failed lookups are cached, 1000 of each by default. The least recently used
+// are dropped.
+func WithAccountKeyCacheSize(size int) AccountKeysOption {
+	return func(k *AccountKeys) {
+		k.size = size
+	}
+}
+
+// WithAccountKeyRetryInterval sets how long an account whose key lookup
+// failed with an error other than ErrKeyNotFound is not looked up again for.
+// Tokens of the account are rejected with the same error until then.
+func WithAccountKeyRetryInterval(interval time.Duration) AccountKeysOption {
+	return func(k *AccountKeys) {
+		k.retry = interval
+	}
+}
+
+// WithAccountKeyClock sets the Clock used to expire cached keys, instead of
+// the system clock
+func WithAccountKeyClock(clock Clock) AccountKeysOption {
+	return func(k *AccountKeys) {
+		k.clock = clock
+	}
+}
+
+// NewAccountKeys creates AccountKeys caching the keys from 'provider'. Use
+// WithAccountSigningKeys to sign with them and WithAccountVerificationKeys to
+// verify tokens signed with them.
+func NewAccountKeys(provider AccountKeyProvider, opts ...AccountKeysOption) *AccountKeys {
+	k := &AccountKeys{
+		provider: provider,
+		ttl:      defaultAccountKeyCacheTTL,
+		size:     defaultAccountKeyCacheSize,
+		retry:    defaultAccountKeyRetryInterval,
+		clock:    systemClock{},
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	k.signers = newAccountKeyCache(k.size)
+	k.verifiers = newAccountKeyCache(k.size)
+	k.failed = newAccountKeyCache(k.size)
+	return k
+}
+
+// accountSigner is a cached signing key
+type accountSigner struct {
+	kid    string
+	signer Signer
+}
+
+// accountVerifier is a cached verification key
+type accountVerifier struct {
+	kid string
+	key crypto.PublicKey
+}
+
+// signingKey returns the signing key of 'accountID', or an error wrapping
+// ErrKeyNotFound if it has none
+func (k *AccountKeys) signingKey(accountID string) (accountSigner, error) {
+	value, err := k.lookup(k.signers, accountID, func() (interface{}, error) {
+		kid, signer, err := k.provider.SigningKey(accountID)
+		if err != nil {
+			return nil, err
+		}
+		if kid == "" || signer == nil {
+			return nil, fmt.Errorf("missing kid or signer for the key of account %s", accountID)
+		}
+		return accountSigner{kid: kid, signer: signer}, nil
+	})
+	if err != nil {
+		return accountSigner{}, err
+	}
+
+	return value.(accountSigner), nil
+}
+
+// verificationKey returns the verification key of 'accountID', or an error
+// wrapping ErrKeyNotFound if it has none
+func (k *AccountKeys) verificationKey(accountID string) (accountVerifier, error) {
+	value, err := k.lookup(k.verifiers, accountID, func() (interface{}, error) {
+		kid, key, err := k.provider.VerificationKey(accountID)
+		if err != nil {
+			return nil, err
+		}
+		if kid == "" || key == nil {
+			return nil, fmt.Errorf("missing kid or key for the key of account %s", accountID)
+		}
+		return accountVerifier{kid: kid, key: key}, nil
+	})
+	if err != nil {
+		return accountVerifier{}, err
+	}
+
+	return value.(accountVerifier), nil
+}
+
+// lookup returns the key for 'accountID' in 'cache', calling 'load' and
+// caching the result if it is missing or expired. A failed lookup is cached
+// in the failed cache, so 'load' is not called again for the account until it
+// expires.
+func (k *AccountKeys) lookup(cache *accountKeyCache, accountID string, load func() (interface{}, error)) (interface{}, error) {
+	now := k.clock.Now()
+	if value, ok := cache.get(accountID, now); ok {
+		return value, nil
+	}
+	if value, ok := k.failed.get(accountID, now); ok {
+		return nil, value.(error)
+	}
+
+	value, err := load()
+	switch {
+	case err == nil:
+		cache.add(accountID, value, now.Add(k.ttl))
+	case errors.Is(err, ErrKeyNotFound):
+		k.failed.add(accountID, err, now.Add(k.ttl))
+	default:
+		// retried sooner, as the provider may be briefly unavailable
+		k.failed.add(accountID, err, now.Add(k.retry))
+	}
+	return value, err
+}
+
+// accountKeyCache is a bounded, least recently used cache of account keys
+// that is safe for concurrent use
+type accountKeyCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used at the front
+}
+
+type accountKeyCacheEntry struct {
+	name      string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func newAccountKeyCache(size int) *accountKeyCache {
+	return &accountKeyCache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// get returns the cached value for 'name' if it has not expired at 'now'
+func (c *accountKeyCache) get(name string, now time.Time) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[name]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*accountKeyCacheEntry)
+	if !now.Before(entry.expiresAt) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// add caches 'value' for 'name' until 'expiresAt'
+func (c *accountKeyCache) add(name string, value interface{}, expiresAt time.Time) {
+	if c.size <= 0 {
+		return
+	}
+	entry := &accountKeyCacheEntry{
+		name:      name,
+		value:     value,
+		expiresAt: expiresAt,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[name]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[name] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry from the cache. Callers must hold mu.
+func (c *accountKeyCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*accountKeyCacheEntry).name)
+}
+
+// WithAccountSigningKeys signs the tokens of accounts with a key of their own
+// with that key instead of the Encoder's, picking it by Payload.Customer. The
+// "kid" header is set to the account key's kid. Tokens without an account, or
+// for accounts without a key, are signed with the Encoder's key.
+func WithAccountSigningKeys(keys *AccountKeys) EncodeOption {
+	return func(c *encodeConfig) {
+		c.accountKeys = keys
+	}
+}
+
+// WithAccountVerificationKeys binds the tokens of accounts with a key of their
+// own to that key: they are verified with it, and rejected unless their "kid"
+// header is its kid, so neither another account's key, the Decoder's key nor
+// a certificate chain in an "x5c" header can sign tokens for the account.
+// Tokens without an account, or for accounts without a key, are verified with
+// the Decoder's keys. Use WithAllowedAlgorithms if account keys use a different
+// algorithm to the Decoder's key.
+func WithAccountVerificationKeys(keys *AccountKeys) DecoderOption {
+	return func(d *Decoder) {
+		d.accountKeys = keys
+	}
+}
+
+// accountSigner returns the Signer and kid for the account of 'payload', or a
+// nil Signer if the Encoder's key should be used
+func (c encodeConfig) accountSigner(payload Payload) (Signer, string, error) {
+	if c.accountKeys == nil || payload.Customer == "" {
+		return nil, "", nil
+	}
+
+	key, err := c.accountKeys.signingKey(payload.Customer)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load signing key for account %s: %w", payload.Customer, err)
+	}
+
+	signer := key.signer
+	if s, ok := signer.(snapshotSigner); ok {
+		signer = s.snapshot()
+	}
+	return signer, key.kid, nil
+}
+
+// accountKey returns the verification key for 'token' if its "accountId"
+// claim is an account with a key of its own, or false if the Decoder's keys
+// should be used. It runs before the signature is checked, and the account key
+// only verifies the signature of a token for that account.
+func (jwt Decoder) accountKey(token *jwtgo.Token) (interface{}, bool, error) {
+	if jwt.accountKeys == nil {
+		return nil, false, nil
+	}
+	claims, ok := token.Claims.(jwtgo.MapClaims)
+	if !ok {
+		return nil, false, nil
+	}
+	accountID := accountClaim(claims)
+	if accountID == "" {
+		return nil, false, nil
+	}
+
+	key, err := jwt.accountKeys.verificationKey(accountID)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load verification key for account %s: %w", accountID, err)
+	}
+
+	// a certificate chain can't stand in for the account's key, whoever issued it
+	if _, ok := token.Header["x5c"]; ok {
+		return nil, false, ErrInvalidClaim{Name: "accountId", Err: ErrAccountKeyMismatch}
+	}
+	if kid, _ := token.Header["kid"].(string); kid != key.kid {
+		return nil, false, ErrInvalidClaim{Name: "accountId", Err: ErrAccountKeyMismatch}
+	}
+	return key.key, true, nil
+}
@@ -0,0 +1,256 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAccountKeys is an AccountKeyProvider holding an ECDSA key for each
+// account, with the kid "<account>-key"
+type testAccountKeys struct {
+	keys  map[string]*ecdsa.PrivateKey
+	err   error
+	calls int
+}
+
+func newTestAccountKeys(t *testing.T, accounts ...string) *testAccountKeys {
+	p := &testAccountKeys{keys: map[string]*ecdsa.PrivateKey{}}
+	for _, account := range accounts {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.Nil(t, err)
+		p.keys[account] = key
+	}
+	return p
+}
+
+func (p *testAccountKeys) SigningKey(accountID string) (string, Signer, error) {
+	p.calls++
+	if p.err != nil {
+		return "", nil, p.err
+	}
+	key, ok := p.keys[accountID]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: no key for account %s", ErrKeyNotFound, accountID)
+	}
+
+	signer, err := NewKeySigner(key)
+	return accountID + "-key", signer, err
+}
+
+func (p *testAccountKeys) VerificationKey(accountID string) (string, crypto.PublicKey, error) {
+	p.calls++
+	if p.err != nil {
+		return "", nil, p.err
+	}
+	key, ok := p.keys[accountID]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: no key for account %s", ErrKeyNotFound, accountID)
+	}
+
+	return accountID + "-key", key.Public(), nil
+}
+
+func accountKeysCoders(t *testing.T, keys *AccountKeys) (Encoder, Decoder) {
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem", WithAccountSigningKeys(keys))
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub",
+		WithAccountVerificationKeys(keys), WithAllowedAlgorithms("RS256", "ES256"))
+	require.Nil(t, err)
+
+	return encoder, decoder
+}
+
+func Test_AccountKeys_EncodeDecode(t *testing.T) {
+	encoder, decoder := accountKeysCoders(t, NewAccountKeys(newTestAccountKeys(t, "abc123")))
+
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	parsed, _, err := new(jwtgo.Parser).ParseUnverified(token, jwtgo.MapClaims{})
+	require.Nil(t, err)
+	assert.Equal(t, "ES256", parsed.Header["alg"])
+	assert.Equal(t, "abc123-key", parsed.Header["kid"])
+
+	payload, err := decoder.Decode(token)
+	require.Nil(t, err)
+	assert.Equal(t, "abc123", payload.Customer)
+}
+
+func Test_AccountKeys_Refresh(t *testing.T) {
+	encoder, decoder := accountKeysCoders(t, NewAccountKeys(newTestAccountKeys(t, "abc123")))
+
+	pair, err := encoder.EncodePair(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	// the account of a refresh token is nested, but still bound to its key
+	rotated, err := encoder.Refresh(pair.RefreshToken, decoder, NewMemoryRefreshStore())
+	require.Nil(t, err)
+	payload, err := decoder.Decode(rotated.AccessToken)
+	require.Nil(t, err)
+	assert.Equal(t, "abc123", payload.Customer)
+}
+
+func Test_AccountKeys_GlobalKey(t *testing.T) {
+	encoder, decoder := accountKeysCoders(t, NewAccountKeys(newTestAccountKeys(t, "abc123")))
+
+	token, err := encoder.Encode(Payload{Customer: "def456", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	parsed, _, err := new(jwtgo.Parser).ParseUnverified(token, jwtgo.MapClaims{})
+	require.Nil(t, err)
+	assert.Equal(t, "RS256", parsed.Header["alg"])
+	assert.Nil(t, parsed.Header["kid"])
+
+	payload, err := decoder.Decode(token)
+	require.Nil(t, err)
+	assert.Equal(t, "def456", payload.Customer)
+}
+
+func Test_AccountKeys_Mismatch(t *testing.T) {
+	provider := newTestAccountKeys(t, "abc123", "def456")
+	_, decoder := accountKeysCoders(t, NewAccountKeys(provider))
+
+	// tokens for def456 signed with the key of abc123, with either kid
+	signer, err := NewKeySigner(provider.keys["abc123"])
+	require.Nil(t, err)
+	cases := map[string]error{
+		"abc123-key": ErrAccountKeyMismatch,
+		"def456-key": ErrSignatureInvalid,
+	}
+	for kid, expected := range cases {
+		forger, err := NewEncoderFromSigner(signer, WithKeyID(kid))
+		require.Nil(t, err)
+		token, err := forger.Encode(Payload{Customer: "def456", RealUser: "xyz234", EffectiveUser: "xyz345"})
+		require.Nil(t, err)
+
+		_, err = decoder.Decode(token)
+		assert.True(t, errors.Is(err, expected), "%s: got %v", kid, err)
+	}
+}
+
+func Test_AccountKeys_GlobalKeyForAccountWithKey(t *testing.T) {
+	_, decoder := accountKeysCoders(t, NewAccountKeys(newTestAccountKeys(t, "abc123")))
+
+	// the global key can't sign tokens for an account with its own key
+	global, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+	token, err := global.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	_, err = decoder.Decode(token)
+	assert.True(t, errors.Is(err, ErrAccountKeyMismatch))
+	assert.True(t, errors.Is(err, ErrInvalidClaim{Name: "accountId"}))
+}
+
+func Test_AccountKeys_X5C(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	ca := newCertificate(t, &caKey.PublicKey, nil, caKey, time.Now().Add(time.Hour))
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	priKey, pubKey := devKeys(t)
+	cert := newCertificate(t, pubKey, ca, caKey, time.Now().Add(time.Hour))
+
+	decoder := NewDecoderFromKeySet(nil, WithRootCAs(roots),
+		WithAccountVerificationKeys(NewAccountKeys(newTestAccountKeys(t, "abc123"))))
+	sign := func(accountID string) string {
+		token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, jwtgo.MapClaims{
+			"accountId":       accountID,
+			"realUserId":      "xyz234",
+			"effectiveUserId": "xyz345",
+		})
+		token.Header["x5c"] = []string{base64.StdEncoding.EncodeToString(cert.Raw)}
+		signed, err := token.SignedString(priKey)
+		require.Nil(t, err)
+		return signed
+	}
+
+	// a trusted certificate can't sign tokens for an account with its own key
+	_, err = decoder.Decode(sign("abc123"))
+	assert.True(t, errors.Is(err, ErrAccountKeyMismatch))
+
+	payload, err := decoder.Decode(sign("def456"))
+	require.Nil(t, err)
+	assert.Equal(t, "def456", payload.Customer)
+}
+
+func Test_AccountKeys_Cache(t *testing.T) {
+	clock := newTestClock()
+	provider := newTestAccountKeys(t, "abc123")
+	keys := NewAccountKeys(provider, WithAccountKeyCacheTTL(time.Minute), WithAccountKeyClock(clock))
+
+	for i := 0; i < 3; i++ {
+		_, err := keys.signingKey("abc123")
+		require.Nil(t, err)
+		_, err = keys.signingKey("def456")
+		assert.True(t, errors.Is(err, ErrKeyNotFound))
+	}
+	assert.Equal(t, 2, provider.calls)
+
+	clock.advance(time.Minute)
+	_, err := keys.signingKey("abc123")
+	require.Nil(t, err)
+	assert.Equal(t, 3, provider.calls)
+
+	// failures other than a missing key are retried sooner
+	provider.err = errors.New("secrets manager unavailable")
+	for i := 0; i < 2; i++ {
+		_, err = keys.verificationKey("abc123")
+		assert.True(t, errors.Is(err, provider.err))
+	}
+	assert.Equal(t, 4, provider.calls)
+
+	provider.err = nil
+	clock.advance(defaultAccountKeyRetryInterval)
+	_, err = keys.verificationKey("abc123")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, provider.calls)
+}
+
+func Test_AccountKeys_MissingAccounts(t *testing.T) {
+	provider := newTestAccountKeys(t, "abc123", "def456")
+	keys := NewAccountKeys(provider, WithAccountKeyCacheSize(2))
+
+	_, err := keys.verificationKey("abc123")
+	require.Nil(t, err)
+
+	// a flood of made up accounts doesn't evict real keys, or stop accounts
+	// that exist being looked up
+	for i := 0; i < 150; i++ {
+		_, err = keys.verificationKey(fmt.Sprintf("made-up-%d", i))
+		assert.True(t, errors.Is(err, ErrKeyNotFound))
+	}
+	assert.Equal(t, 151, provider.calls)
+
+	_, err = keys.verificationKey("abc123")
+	assert.Nil(t, err)
+	assert.Equal(t, 151, provider.calls)
+	_, err = keys.verificationKey("def456")
+	assert.Nil(t, err)
+
+	// each made up account is only looked up once
+	_, err = keys.verificationKey("made-up-149")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+	assert.Equal(t, 152, provider.calls)
+}
+
+func Test_AccountKeys_ProviderError(t *testing.T) {
+	provider := newTestAccountKeys(t, "abc123")
+	encoder, _ := accountKeysCoders(t, NewAccountKeys(provider))
+
+	provider.err = errors.New("secrets manager unavailable")
+	_, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	assert.True(t, errors.Is(err, provider.err))
+}
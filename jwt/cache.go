@@ -0,0 +1,163 @@
+package jwt
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+)
+
+// CacheStats reports how well a Decoder's verified-token cache is performing
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int
+}
+
+// WithTokenCache caches up to 'size' successfully decoded tokens, so a token
+// seen on many requests in a row is only verified once. Tokens are cached by
+// a hash of the token string and never beyond their "exp" claim plus any
+// leeway; tokens without an "exp" claim are not cached. Revocations are still
+// checked on every Decode.
+//
+// A cached token is not verified again when the keys change: it is accepted
+// until its entry expires even if the key that signed it has since been
+// rotated out, reloaded from its KeySource or dropped from a JWKS, or its
+// certificate has expired. If tokens signed with a compromised key must stop
+// being accepted at once, revoke them or don't use the cache.
+func WithTokenCache(size int) DecoderOption {
+	return func(d *Decoder) {
+		d.cache = newTokenCache(size)
+	}
+}
+
+// CacheStats returns the hit/miss statistics for the verified-token cache. It
+// is empty if WithTokenCache was not given.
+func (jwt Decoder) CacheStats() CacheStats {
+	if jwt.cache == nil {
+		return CacheStats{}
+	}
+
+	return jwt.cache.stats()
+}
+
+// tokenCache is a bounded, least recently used cache of decoded tokens that
+// is safe for concurrent use
+type tokenCache struct {
+	size   int
+	hits   uint64
+	misses uint64
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List // most recently used at the front
+}
+
+type tokenCacheEntry struct {
+	key       [sha256.Size]byte
+	payload   Payload
+	expiresAt time.Time
+}
+
+func newTokenCache(size int) *tokenCache {
+	return &tokenCache{
+		size:    size,
+		entries: map[[sha256.Size]byte]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// get returns the cached payload for 'tokenString' if it has not expired at 'now'
+func (c *tokenCache) get(tokenString string, now time.Time) (Payload, bool) {
+	key := sha256.Sum256([]byte(tokenString))
+
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok && !now.Before(el.Value.(*tokenCacheEntry).expiresAt) {
+		c.remove(el)
+		ok = false
+	}
+	var payload Payload
+	if ok {
+		c.order.MoveToFront(el)
+		payload = el.Value.(*tokenCacheEntry).payload
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return Payload{}, false
+	}
+
+	atomic.AddUint64(&c.hits, 1)
+	return copyPayload(payload), true
+}
+
+// add caches 'payload' until the token's "exp" claim plus 'leeway', when the
+// Decoder stops accepting it
+func (c *tokenCache) add(tokenString string, payload Payload, leeway time.Duration) {
+	if c.size <= 0 {
+		return
+	}
+	expiresAt, ok, err := timeClaim(jwtgo.MapClaims(payload.Claims), "exp")
+	if err != nil || !ok {
+		return
+	}
+
+	key := sha256.Sum256([]byte(tokenString))
+	entry := &tokenCacheEntry{
+		key:       key,
+		payload:   copyPayload(payload),
+		expiresAt: expiresAt.Add(leeway),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry from the cache. Callers must hold mu.
+func (c *tokenCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*tokenCacheEntry).key)
+}
+
+func (c *tokenCache) stats() CacheStats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+		Size:   size,
+	}
+}
+
+// copyPayload copies the claims of 'payload' so callers can't change the
+// cached copy
+func copyPayload(payload Payload) Payload {
+	claims := make(Claims, len(payload.Claims))
+	for name, value := range payload.Claims {
+		claims[name] = value
+	}
+
+	payload.Claims = claims
+	if payload.Scopes != nil {
+		payload.Scopes = append([]string(nil), payload.Scopes...)
+	}
+	return payload
+}
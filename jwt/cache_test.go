@@ -0,0 +1,181 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Cache_HitsAndMisses(t *testing.T) {
+	priKey, _ := devKeys(t)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithTokenCache(10))
+	require.Nil(t, err)
+	token := signClaims(t, priKey, jwtgo.MapClaims{"exp": time.Now().Add(time.Minute).Unix()})
+
+	first, err := decoder.Decode(token)
+	assert.Nil(t, err)
+	second, err := decoder.Decode(token)
+	assert.Nil(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Size: 1}, decoder.CacheStats())
+
+	// changing the returned claims must not change the cached copy
+	second.Claims["accountId"] = "changed"
+	third, err := decoder.Decode(token)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", third.Claims["accountId"])
+}
+
+func Test_Cache_InvalidTokensNotCached(t *testing.T) {
+	priKey, _ := devKeys(t)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithTokenCache(10))
+	require.Nil(t, err)
+
+	expired := signClaims(t, priKey, jwtgo.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})
+	for i := 0; i < 2; i++ {
+		_, err = decoder.Decode(expired)
+		assert.True(t, errors.Is(err, ErrTokenExpired))
+	}
+
+	// tokens without an "exp" claim can't be bounded, so aren't cached
+	_, err = decoder.Decode(signClaims(t, priKey, nil))
+	assert.Nil(t, err)
+	assert.Equal(t, CacheStats{Misses: 3}, decoder.CacheStats())
+}
+
+func Test_Cache_NeverOutlivesExpiry(t *testing.T) {
+	cache := newTokenCache(10)
+	now := time.Now()
+	exp := now.Add(time.Minute)
+
+	cache.add("token", Payload{Customer: "abc123", Claims: Claims{"exp": float64(exp.Unix())}}, 0)
+	_, ok := cache.get("token", now)
+	assert.True(t, ok)
+	_, ok = cache.get("token", time.Unix(exp.Unix(), 0))
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.stats().Size)
+}
+
+func Test_Cache_Leeway(t *testing.T) {
+	clock := newTestClock()
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem", WithEncoderClock(clock))
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub",
+		WithDecoderClock(clock), WithLeeway(time.Minute), WithTokenCache(10))
+	require.Nil(t, err)
+
+	token, err := encoder.EncodeWithExpiry(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"}, time.Minute)
+	require.Nil(t, err)
+
+	// past "exp" but within the leeway the token is still cached
+	clock.advance(time.Minute + 30*time.Second)
+	for i := 0; i < 3; i++ {
+		_, err = decoder.Decode(token)
+		require.Nil(t, err)
+	}
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Size: 1}, decoder.CacheStats())
+
+	clock.advance(time.Minute)
+	_, err = decoder.Decode(token)
+	assert.True(t, errors.Is(err, ErrTokenExpired))
+}
+
+func Test_Cache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newTokenCache(2)
+	now := time.Now()
+	claims := Claims{"exp": float64(now.Add(time.Minute).Unix())}
+
+	cache.add("a", Payload{Claims: claims}, 0)
+	cache.add("b", Payload{Claims: claims}, 0)
+	_, ok := cache.get("a", now)
+	assert.True(t, ok)
+	cache.add("c", Payload{Claims: claims}, 0)
+
+	_, ok = cache.get("b", now)
+	assert.False(t, ok)
+	_, ok = cache.get("a", now)
+	assert.True(t, ok)
+	_, ok = cache.get("c", now)
+	assert.True(t, ok)
+	assert.Equal(t, 2, cache.stats().Size)
+}
+
+func Test_Cache_StillChecksRevocation(t *testing.T) {
+	jwtEncoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+	store := NewMemoryRevocationStore()
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithTokenCache(10), WithRevocationStore(store))
+	require.Nil(t, err)
+
+	token, err := jwtEncoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+	payload, err := decoder.Decode(token)
+	require.Nil(t, err)
+
+	require.Nil(t, RevokePayload(store, payload))
+	_, err = decoder.Decode(token)
+	assert.True(t, errors.Is(err, ErrTokenRevoked))
+	assert.Equal(t, uint64(1), decoder.CacheStats().Hits)
+}
+
+func Test_Cache_Concurrent(t *testing.T) {
+	priKey, _ := devKeys(t)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithTokenCache(4))
+	require.Nil(t, err)
+
+	tokens := make([]string, 8)
+	for i := range tokens {
+		tokens[i] = signClaims(t, priKey, jwtgo.MapClaims{
+			"exp": time.Now().Add(time.Minute).Unix(),
+			"jti": fmt.Sprint(i),
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				payload, err := decoder.Decode(tokens[(i+j)%len(tokens)])
+				assert.Nil(t, err)
+				assert.Equal(t, "abc123", payload.Customer)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := decoder.CacheStats()
+	assert.Equal(t, uint64(16*50), stats.Hits+stats.Misses)
+	assert.LessOrEqual(t, stats.Size, 4)
+}
+
+func benchmarkDecode(b *testing.B, opts ...DecoderOption) {
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(b, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", opts...)
+	require.Nil(b, err)
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := decoder.Decode(token); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	benchmarkDecode(b)
+}
+
+func BenchmarkDecodeCached(b *testing.B) {
+	benchmarkDecode(b, WithTokenCache(1000))
+}
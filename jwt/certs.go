@@ -0,0 +1,98 @@
+package jwt
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+)
+
+// WithRootCAs verifies certificate keys against the CAs in 'roots', rather
+// than only checking they have not expired. Tokens with an "x5c" header
+// (RFC 7515 section 4.1.6) are then verified with the key of the certificate
+// chain in the header, if that chain is signed by one of 'roots'.
+func WithRootCAs(roots *x509.CertPool) DecoderOption {
+	return func(d *Decoder) {
+		d.roots = roots
+	}
+}
+
+// verificationKey returns the key to verify a token with, checking it is still
+// valid if it is a certificate
+func (jwt Decoder) verificationKey(key interface{}) (interface{}, error) {
+	cert, ok := key.(*x509.Certificate)
+	if !ok {
+		return key, nil
+	}
+
+	if err := jwt.verifyCertificate(cert, nil); err != nil {
+		return nil, err
+	}
+	return cert.PublicKey, nil
+}
+
+// verifyCertificate checks 'cert' has not expired and, if the Decoder has
+// root CAs, that it chains to one of them through 'intermediates'
+func (jwt Decoder) verifyCertificate(cert *x509.Certificate, intermediates *x509.CertPool) error {
+	now := jwt.now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("%w: certificate '%s' is only valid from %s to %s", ErrCertificateInvalid,
+			cert.Subject, cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+	}
+	if jwt.roots == nil {
+		return nil
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         jwt.roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrCertificateInvalid, err)
+	}
+	return nil
+}
+
+// x5cKey returns the key of the certificate chain in the "x5c" header of
+// 'token', once the chain has been verified against the root CAs
+func (jwt Decoder) x5cKey(token *jwtgo.Token) (interface{}, error) {
+	header, ok := token.Header["x5c"].([]interface{})
+	if !ok || len(header) == 0 {
+		return nil, fmt.Errorf("%w: invalid 'x5c' in jwt header", ErrMalformed)
+	}
+
+	chain := make([]*x509.Certificate, 0, len(header))
+	for _, value := range header {
+		encoded, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid 'x5c' in jwt header", ErrMalformed)
+		}
+		// unlike the rest of the token, "x5c" uses standard base64
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid 'x5c' in jwt header: %s", ErrMalformed, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid 'x5c' in jwt header: %s", ErrMalformed, err)
+		}
+		chain = append(chain, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	if err := jwt.verifyCertificate(chain[0], intermediates); err != nil {
+		return nil, err
+	}
+	if _, err := algorithmForKey(chain[0]); err != nil {
+		return nil, err
+	}
+
+	return chain[0].PublicKey, nil
+}
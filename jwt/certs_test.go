@@ -0,0 +1,157 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCertificate creates a certificate for 'pub' signed by 'parent', or self
+// signed if parent is nil
+func newCertificate(t *testing.T, pub interface{}, parent *x509.Certificate, parentKey interface{}, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "gocampers test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent = template
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, parentKey)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return cert
+}
+
+func pemEncodeCertificate(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+func Test_Certificate_Decode(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	cert := newCertificate(t, pubKey, nil, priKey, time.Now().Add(time.Hour))
+	token := signWithKid(t, priKey, "")
+
+	for name, certBytes := range map[string][]byte{"PEM": pemEncodeCertificate(cert), "DER": cert.Raw} {
+		t.Run(name, func(t *testing.T) {
+			decoder, err := NewDecoderFromCertificate(certBytes)
+			require.Nil(t, err)
+			_, err = decoder.Decode(token)
+			assert.Nil(t, err)
+
+			decoder, err = NewDecoderFromBytes(certBytes)
+			require.Nil(t, err)
+			_, err = decoder.Decode(token)
+			assert.Nil(t, err)
+		})
+	}
+
+	_, err := NewDecoderFromCertificate(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: cert.RawSubjectPublicKeyInfo}))
+	assert.NotNil(t, err)
+}
+
+func Test_Certificate_Expired(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	expired := newCertificate(t, pubKey, nil, priKey, time.Now().Add(-time.Minute))
+
+	_, err := NewDecoderFromCertificate(pemEncodeCertificate(expired))
+	assert.True(t, errors.Is(err, ErrCertificateInvalid))
+
+	// certificates in a key set are checked when they are used
+	decoder := NewDecoderFromKeySet(NewStaticKeySet(nil, expired))
+	_, err = decoder.Decode(signWithKid(t, priKey, ""))
+	assert.True(t, errors.Is(err, ErrCertificateInvalid))
+}
+
+func Test_Certificate_RootCAs(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	ca := newCertificate(t, &caKey.PublicKey, nil, caKey, time.Now().Add(time.Hour))
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	priKey, pubKey := devKeys(t)
+	trusted := newCertificate(t, pubKey, ca, caKey, time.Now().Add(time.Hour))
+	selfSigned := newCertificate(t, pubKey, nil, priKey, time.Now().Add(time.Hour))
+
+	decoder, err := NewDecoderFromCertificate(pemEncodeCertificate(trusted), WithRootCAs(roots))
+	require.Nil(t, err)
+	_, err = decoder.Decode(signWithKid(t, priKey, ""))
+	assert.Nil(t, err)
+
+	_, err = NewDecoderFromCertificate(pemEncodeCertificate(selfSigned), WithRootCAs(roots))
+	assert.True(t, errors.Is(err, ErrCertificateInvalid))
+}
+
+func Test_Certificate_X5C(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	ca := newCertificate(t, &caKey.PublicKey, nil, caKey, time.Now().Add(time.Hour))
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	priKey, pubKey := devKeys(t)
+	trusted := newCertificate(t, pubKey, ca, caKey, time.Now().Add(time.Hour))
+	selfSigned := newCertificate(t, pubKey, nil, priKey, time.Now().Add(time.Hour))
+
+	sign := func(x5c interface{}) string {
+		token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, jwtgo.MapClaims{
+			"accountId":       "abc123",
+			"realUserId":      "xyz234",
+			"effectiveUserId": "xyz345",
+		})
+		token.Header["x5c"] = x5c
+		signed, err := token.SignedString(priKey)
+		require.Nil(t, err)
+		return signed
+	}
+	chain := func(certs ...*x509.Certificate) []string {
+		encoded := []string{}
+		for _, cert := range certs {
+			encoded = append(encoded, base64.StdEncoding.EncodeToString(cert.Raw))
+		}
+		return encoded
+	}
+
+	decoder := NewDecoderFromKeySet(nil, WithRootCAs(roots))
+	payload, err := decoder.Decode(sign(chain(trusted, ca)))
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", payload.Customer)
+
+	_, err = decoder.Decode(sign(chain(selfSigned)))
+	assert.True(t, errors.Is(err, ErrCertificateInvalid))
+
+	_, err = decoder.Decode(sign([]string{"not base64!"}))
+	assert.True(t, errors.Is(err, ErrMalformed))
+
+	// the key in the chain must match the signature
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	other := newCertificate(t, &otherKey.PublicKey, ca, caKey, time.Now().Add(time.Hour))
+	_, err = decoder.Decode(sign(chain(other)))
+	assert.True(t, errors.Is(err, ErrSignatureInvalid))
+
+	// without root CAs the header is ignored
+	_, err = NewDecoderFromKeySet(nil).Decode(sign(chain(trusted)))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,56 @@
+package jwt
+
+import (
+	"encoding/json"
+)
+
+// Claims is the full set of claims in a token, as decoded from its JSON
+// payload. Numbers are float64, arrays are []interface{} and objects are
+// map[string]interface{}.
+type Claims map[string]interface{}
+
+// Decode unmarshals the claims into 'v', which should be a pointer to a struct
+// with json tags, eg.
+//
+//	var extra struct {
+//		Roles  []string `json:"roles"`
+//		Locale string   `json:"locale"`
+//	}
+//	err := payload.Claims.Decode(&extra)
+func (c Claims) Decode(v interface{}) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// String returns the claim 'name' if it is present and a string
+func (c Claims) String(name string) (string, bool) {
+	s, ok := c[name].(string)
+	return s, ok
+}
+
+// Strings returns the claim 'name' if it is present and an array of strings
+func (c Claims) Strings(name string) ([]string, bool) {
+	if strs, ok := c[name].([]string); ok {
+		return strs, true
+	}
+
+	values, ok := c[name].([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		strs = append(strs, s)
+	}
+
+	return strs, true
+}
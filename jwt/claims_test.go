@@ -0,0 +1,91 @@
+package jwt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type customClaims struct {
+	AccountID  string   `json:"accountId"`
+	Roles      []string `json:"roles"`
+	Locale     string   `json:"locale"`
+	EmployeeID int      `json:"employeeId"`
+	Features   struct {
+		Beta bool `json:"beta"`
+	} `json:"features"`
+}
+
+func encodeWithExtraClaims(t *testing.T) string {
+	t.Helper()
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+
+	token, err := encoder.Encode(Payload{
+		Customer:      "abc123",
+		RealUser:      "xyz234",
+		EffectiveUser: "xyz345",
+		Claims: Claims{
+			"roles":      []string{"admin", "manager"},
+			"locale":     "en-AU",
+			"employeeId": 42,
+			"features":   map[string]interface{}{"beta": true},
+			// the identity claims can't be replaced by extra claims
+			"accountId": "hijacked",
+		},
+	})
+	require.Nil(t, err)
+	return token
+}
+
+func Test_Claims_Encode_Decode(t *testing.T) {
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	payload, err := decoder.Decode(encodeWithExtraClaims(t))
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", payload.Customer)
+	assert.Equal(t, "abc123", payload.Claims["accountId"])
+
+	locale, ok := payload.Claims.String("locale")
+	assert.True(t, ok)
+	assert.Equal(t, "en-AU", locale)
+
+	roles, ok := payload.Claims.Strings("roles")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"admin", "manager"}, roles)
+
+	_, ok = payload.Claims.Strings("locale")
+	assert.False(t, ok)
+	_, ok = payload.Claims.String("missing")
+	assert.False(t, ok)
+
+	assert.Contains(t, payload.Claims, "exp")
+	assert.Contains(t, payload.Claims, "iat")
+}
+
+func Test_Claims_DecodeInto(t *testing.T) {
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	var custom customClaims
+	payload, err := decoder.DecodeInto(encodeWithExtraClaims(t), &custom)
+	assert.Nil(t, err)
+	assert.Equal(t, "xyz345", payload.EffectiveUser)
+	assert.Equal(t, "abc123", custom.AccountID)
+	assert.Equal(t, []string{"admin", "manager"}, custom.Roles)
+	assert.Equal(t, "en-AU", custom.Locale)
+	assert.Equal(t, 42, custom.EmployeeID)
+	assert.True(t, custom.Features.Beta)
+}
+
+func Test_Claims_DecodeInto_InvalidToken(t *testing.T) {
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	var custom customClaims
+	_, err = decoder.DecodeInto("INVALID.TOKEN.", &custom)
+	assert.NotNil(t, err)
+	assert.Equal(t, customClaims{}, custom)
+}
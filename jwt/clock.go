@@ -0,0 +1,72 @@
+package jwt
+
+import "time"
+
+// Clock tells the time tokens are issued and validated at. The system clock
+// is used by default; tests and replay tools can supply their own to pin the
+// time and run expiry scenarios deterministically.
+//
+// Each component taking a Clock has an option named after it: WithDecoderClock,
+// WithEncoderClock, WithStoreClock, WithAccountKeyClock, WithJWKSClock and
+// WithKeySourceClock.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+}
+
+// ClockFunc is a Clock calling a function for the time
+type ClockFunc func() time.Time
+
+// Now calls f
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// systemClock is the Clock reading the system time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// defaultStoreReloadInterval is how often a FileRevocationStore re-reads its
+// file
+const defaultStoreReloadInterval = time.Second
+
+// StoreOption configures a RevocationStore or RefreshStore created by this
+// package
+type StoreOption func(*storeConfig)
+
+type storeConfig struct {
+	clock          Clock
+	reloadInterval time.Duration
+}
+
+// WithStoreClock sets the Clock used to decide when entries in the store have
+// expired. It should match the Clock of the Decoder using the store.
+func WithStoreClock(clock Clock) StoreOption {
+	return func(c *storeConfig) {
+		c.clock = clock
+	}
+}
+
+// WithStoreReloadInterval sets how often a FileRevocationStore re-reads its
+// file for revocations by other processes, once a second by default. An
+// interval of 0 re-reads it for every lookup.
+func WithStoreReloadInterval(interval time.Duration) StoreOption {
+	return func(c *storeConfig) {
+		c.reloadInterval = interval
+	}
+}
+
+func newStoreConfig(opts []StoreOption) storeConfig {
+	config := storeConfig{
+		clock:          systemClock{},
+		reloadInterval: defaultStoreReloadInterval,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return config
+}
@@ -0,0 +1,118 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClock is a Clock that only moves when told to
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2021, 11, 8, 9, 0, 0, 0, time.UTC)}
+}
+
+func Test_Clock_EncodeDecode(t *testing.T) {
+	clock := newTestClock()
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem", WithEncoderClock(clock))
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithDecoderClock(clock))
+	require.Nil(t, err)
+
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	payload, err := decoder.Decode(token)
+	require.Nil(t, err)
+	iat, _, _ := timeClaim(jwtgo.MapClaims(payload.Claims), "iat")
+	exp, _, _ := timeClaim(jwtgo.MapClaims(payload.Claims), "exp")
+	assert.Equal(t, clock.now, iat.UTC())
+	assert.Equal(t, clock.now.Add(defaultExpiry), exp.UTC())
+
+	clock.advance(defaultExpiry + time.Second)
+	_, err = decoder.Decode(token)
+	assert.True(t, errors.Is(err, ErrTokenExpired))
+}
+
+func Test_Clock_Cache(t *testing.T) {
+	clock := newTestClock()
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem", WithEncoderClock(clock))
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithDecoderClock(clock), WithTokenCache(10))
+	require.Nil(t, err)
+
+	token, err := encoder.EncodeWithExpiry(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"}, time.Minute)
+	require.Nil(t, err)
+
+	_, err = decoder.Decode(token)
+	require.Nil(t, err)
+	_, err = decoder.Decode(token)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(1), decoder.CacheStats().Hits)
+
+	clock.advance(time.Minute + time.Second)
+	_, err = decoder.Decode(token)
+	assert.True(t, errors.Is(err, ErrTokenExpired))
+}
+
+func Test_Clock_Stores(t *testing.T) {
+	clock := newTestClock()
+	start := clock.now
+	expiresAt := start.Add(time.Minute)
+
+	fileStore, err := NewFileRevocationStore(t.TempDir()+"/revocations.json", WithStoreClock(clock))
+	require.Nil(t, err)
+	for _, store := range []RevocationStore{NewMemoryRevocationStore(WithStoreClock(clock)), fileStore} {
+		clock.now = start
+		require.Nil(t, store.RevokeToken("token-1", expiresAt))
+		revoked, err := store.IsTokenRevoked("token-1")
+		require.Nil(t, err)
+		assert.True(t, revoked)
+
+		clock.advance(time.Minute)
+		revoked, err = store.IsTokenRevoked("token-1")
+		require.Nil(t, err)
+		assert.False(t, revoked)
+	}
+
+	clock.now = start
+	refreshStore := NewMemoryRefreshStore(WithStoreClock(clock))
+	require.Nil(t, refreshStore.RevokeFamily("family-1", expiresAt))
+	redeemed, err := refreshStore.Redeem("family-1", "token-1", expiresAt)
+	require.Nil(t, err)
+	assert.False(t, redeemed)
+
+	clock.advance(time.Minute)
+	redeemed, err = refreshStore.Redeem("family-1", "token-2", expiresAt.Add(time.Minute))
+	require.Nil(t, err)
+	assert.True(t, redeemed)
+}
+
+func Test_Clock_Certificate(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	cert := newCertificate(t, pubKey, nil, priKey, time.Now().Add(time.Hour))
+
+	clock := &testClock{now: time.Now()}
+	decoder, err := NewDecoderFromCertificate(pemEncodeCertificate(cert), WithDecoderClock(clock))
+	require.Nil(t, err)
+
+	token := signWithKid(t, priKey, "")
+	clock.advance(2 * time.Hour)
+	_, err = decoder.Decode(token)
+	assert.True(t, errors.Is(err, ErrCertificateInvalid))
+}
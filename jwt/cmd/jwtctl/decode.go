@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// decode prints the header and claims of a token without verifying it
+func decode(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("decode", stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	token, err := readToken(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 && len(parts) != 5 {
+		return fmt.Errorf("malformed token: expected 3 or 5 parts, got %d", len(parts))
+	}
+
+	header, err := decodeSegment(parts[0])
+	if err != nil {
+		return fmt.Errorf("malformed header: %w", err)
+	}
+	if err := printJSON(stdout, "header", header); err != nil {
+		return err
+	}
+
+	if len(parts) == 5 {
+		_, err = fmt.Fprintln(stdout, "\nclaims are encrypted")
+		return err
+	}
+
+	claims, err := decodeSegment(parts[1])
+	if err != nil {
+		return fmt.Errorf("malformed claims: %w", err)
+	}
+	if err := printJSON(stdout, "\nclaims", claims); err != nil {
+		return err
+	}
+	return printTimes(stdout, claims)
+}
+
+func decodeSegment(segment string) (map[string]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+func printJSON(w io.Writer, title string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s:\n%s\n", title, data)
+	return err
+}
+
+// printTimes prints the time based claims as readable times
+func printTimes(w io.Writer, claims map[string]interface{}) error {
+	for _, name := range []string{"iat", "nbf", "exp"} {
+		seconds, ok := claims[name].(float64)
+		if !ok {
+			continue
+		}
+
+		at := time.Unix(int64(seconds), 0).UTC()
+		if _, err := fmt.Fprintf(w, "%s: %s\n", name, at.Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
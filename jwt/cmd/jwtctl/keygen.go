@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// keygen generates a development key pair, writing the PKCS#8 private key and
+// PKIX public key to <out>.pem and <out>.pub, or to stdout
+func keygen(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("keygen", stderr)
+	keyType := fs.String("type", "rsa", "key type: rsa, ec or ed25519")
+	bits := fs.Int("bits", 2048, "RSA key size")
+	curve := fs.String("curve", "P-256", "EC curve: P-256, P-384 or P-521")
+	out := fs.String("out", "", "write the keys to <out>.pem and <out>.pub rather than stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	priKey, err := generateKey(*keyType, *bits, *curve)
+	if err != nil {
+		return err
+	}
+	priDER, err := x509.MarshalPKCS8PrivateKey(priKey)
+	if err != nil {
+		return err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(priKey.Public())
+	if err != nil {
+		return err
+	}
+	priPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priDER})
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+
+	if *out == "" {
+		_, err = fmt.Fprintf(stdout, "%s%s", priPEM, pubPEM)
+		return err
+	}
+
+	if err := ioutil.WriteFile(*out+".pem", priPEM, 0600); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*out+".pub", pubPEM, 0644); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "wrote %s.pem and %s.pub\n", *out, *out)
+	return err
+}
+
+func generateKey(keyType string, bits int, curve string) (crypto.Signer, error) {
+	switch keyType {
+	case "rsa":
+		return rsa.GenerateKey(rand.Reader, bits)
+	case "ec":
+		var c elliptic.Curve
+		switch curve {
+		case "P-256":
+			c = elliptic.P256()
+		case "P-384":
+			c = elliptic.P384()
+		case "P-521":
+			c = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", curve)
+		}
+		return ecdsa.GenerateKey(c, rand.Reader)
+	case "ed25519":
+		_, priKey, err := ed25519.GenerateKey(rand.Reader)
+		return priKey, err
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s'", keyType)
+	}
+}
@@ -0,0 +1,96 @@
+// Command jwtctl generates development keys and mints, decodes and verifies
+// tokens, for debugging services that use the jwt package.
+//
+// Usage:
+//
+//	jwtctl keygen [-type rsa|ec|ed25519] [-out prefix]
+//	jwtctl mint -key private.pem [-payload file.json] [-account id -real-user id -effective-user id] [-expiry 10m]
+//	jwtctl decode [token]
+//	jwtctl verify -key public.pem|-jwks jwks.json [token]
+//
+// Tokens are read from stdin when not given as an argument.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const usage = `usage: jwtctl <command> [flags]
+
+commands:
+  keygen   generate a development key pair
+  mint     sign a token with a private key
+  decode   print the header and claims of a token without verifying it
+  verify   verify a token against a public key, JWK or JWKS file
+
+run 'jwtctl <command> -h' for the flags of a command
+`
+
+// command runs a jwtctl command with its arguments
+type command func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error
+
+var commands = map[string]command{
+	"keygen": keygen,
+	"mint":   mint,
+	"decode": decode,
+	"verify": verify,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command '%s'\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err := cmd(args[1:], stdin, stdout, stderr); err != nil {
+		if err == flag.ErrHelp {
+			return 2
+		}
+		fmt.Fprintf(stderr, "jwtctl %s: %s\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// newFlagSet creates the flags for a command, printing errors and usage to
+// 'stderr'
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("jwtctl "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// readToken returns the token given as the only argument, or read from stdin
+func readToken(args []string, stdin io.Reader) (string, error) {
+	switch len(args) {
+	case 0:
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		token := strings.TrimSpace(line)
+		if token == "" {
+			return "", fmt.Errorf("no token given")
+		}
+		return token, nil
+	case 1:
+		return strings.TrimPrefix(strings.TrimSpace(args[0]), "Bearer "), nil
+	default:
+		return "", fmt.Errorf("expected one token, got %d arguments", len(args))
+	}
+}
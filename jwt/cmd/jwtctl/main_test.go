@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runCommand(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func Test_Jwtctl_Keygen_Mint_Verify(t *testing.T) {
+	for _, keyType := range []string{"rsa", "ec", "ed25519"} {
+		t.Run(keyType, func(t *testing.T) {
+			prefix := filepath.Join(t.TempDir(), "dev")
+			_, stderr, code := runCommand(t, "", "keygen", "-type", keyType, "-out", prefix)
+			require.Equal(t, 0, code, stderr)
+
+			token, stderr, code := runCommand(t, "", "mint", "-key", prefix+".pem", "-account", "abc123", "-real-user", "xyz234", "-iss", "jwtctl")
+			require.Equal(t, 0, code, stderr)
+
+			stdout, stderr, code := runCommand(t, token, "verify", "-key", prefix+".pub", "-iss", "jwtctl")
+			assert.Equal(t, 0, code, stderr)
+			assert.Contains(t, stdout, "token is valid")
+			assert.Contains(t, stdout, `"effectiveUserId": "xyz234"`)
+		})
+	}
+}
+
+func Test_Jwtctl_Mint_Payload(t *testing.T) {
+	payloadPath := filepath.Join(t.TempDir(), "payload.json")
+	require.Nil(t, ioutil.WriteFile(payloadPath, []byte(`{"accountId":"abc123","realUserId":"xyz234","effectiveUserId":"xyz345","email":"someone@example.com"}`), 0600))
+
+	token, stderr, code := runCommand(t, "", "mint", "-key", "../../jwt.rs256.key.development.pem", "-payload", payloadPath, "-expiry", "1h")
+	require.Equal(t, 0, code, stderr)
+
+	stdout, stderr, code := runCommand(t, "", "decode", strings.TrimSpace(token))
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, `"alg": "RS256"`)
+	assert.Contains(t, stdout, `"effectiveUserId": "xyz345"`)
+	assert.Contains(t, stdout, `"email": "someone@example.com"`)
+
+	// the payload can also be read from stdin
+	token, stderr, code = runCommand(t, `{"principalType":"service","serviceName":"worker"}`, "mint", "-key", "../../jwt.rs256.key.development.pem", "-payload", "-")
+	require.Equal(t, 0, code, stderr)
+	stdout, _, code = runCommand(t, token, "verify", "-key", "../../jwt.rs256.key.development.pub")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, `"serviceName": "worker"`)
+}
+
+func Test_Jwtctl_Verify_Fails(t *testing.T) {
+	token, _, code := runCommand(t, "", "mint", "-key", "../../jwt.rs256.key.development.pem", "-account", "abc123", "-real-user", "xyz234", "-expiry", "-1m")
+	require.Equal(t, 0, code)
+
+	_, stderr, code := runCommand(t, token, "verify", "-key", "../../jwt.rs256.key.development.pub")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "verification failed")
+	assert.Contains(t, stderr, "token is expired")
+
+	_, stderr, code = runCommand(t, token, "verify")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-key or -jwks is required")
+}
+
+func Test_Jwtctl_Verify_JWKS(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "dev")
+	_, _, code := runCommand(t, "", "keygen", "-type", "ed25519", "-out", prefix)
+	require.Equal(t, 0, code)
+	token, _, code := runCommand(t, "", "mint", "-key", prefix+".pem", "-account", "abc123", "-real-user", "xyz234", "-kid", "2021")
+	require.Equal(t, 0, code)
+
+	pubPEM, err := ioutil.ReadFile(prefix + ".pub")
+	require.Nil(t, err)
+	jwks := map[string]interface{}{"keys": []interface{}{ed25519JWK(t, pubPEM, "2021")}}
+	jwksBytes, err := json.Marshal(jwks)
+	require.Nil(t, err)
+	jwksPath := filepath.Join(dir, "jwks.json")
+	require.Nil(t, ioutil.WriteFile(jwksPath, jwksBytes, 0600))
+
+	stdout, stderr, code := runCommand(t, token, "verify", "-jwks", jwksPath)
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "token is valid")
+}
+
+func Test_Jwtctl_Verify_JWKS_SingleKey(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "dev")
+	_, _, code := runCommand(t, "", "keygen", "-type", "ed25519", "-out", prefix)
+	require.Equal(t, 0, code)
+	token, _, code := runCommand(t, "", "mint", "-key", prefix+".pem", "-account", "abc123", "-real-user", "xyz234")
+	require.Equal(t, 0, code)
+	pubPEM, err := ioutil.ReadFile(prefix + ".pub")
+	require.Nil(t, err)
+
+	// the only key of a set is used for tokens without a kid, with or without
+	// a kid of its own
+	for _, kid := range []string{"", "2021"} {
+		key := ed25519JWK(t, pubPEM, kid)
+		if kid == "" {
+			delete(key, "kid")
+		}
+		jwksBytes, err := json.Marshal(map[string]interface{}{"keys": []interface{}{key}})
+		require.Nil(t, err)
+		jwksPath := filepath.Join(dir, "jwks.json")
+		require.Nil(t, ioutil.WriteFile(jwksPath, jwksBytes, 0600))
+
+		stdout, stderr, code := runCommand(t, token, "verify", "-jwks", jwksPath)
+		assert.Equal(t, 0, code, stderr)
+		assert.Contains(t, stdout, "token is valid")
+	}
+}
+
+func Test_Jwtctl_Usage(t *testing.T) {
+	_, stderr, code := runCommand(t, "")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "usage: jwtctl")
+
+	_, stderr, code = runCommand(t, "", "frobnicate")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "unknown command 'frobnicate'")
+
+	_, stderr, code = runCommand(t, "", "decode", "not-a-token")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "malformed token")
+
+	_, stderr, code = runCommand(t, "", "mint", "-h")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "Usage of jwtctl mint")
+	assert.Contains(t, stderr, "-key")
+}
+
+func ed25519JWK(t *testing.T, pubPEM []byte, kid string) map[string]string {
+	t.Helper()
+	block, _ := pem.Decode(pubPEM)
+	require.NotNil(t, block)
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	require.Nil(t, err)
+
+	return map[string]string{
+		"kty": "OKP",
+		"crv": "Ed25519",
+		"use": "sig",
+		"kid": kid,
+		"x":   base64.RawURLEncoding.EncodeToString(key.(ed25519.PublicKey)),
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cultureamp/gocampers/jwt"
+)
+
+// mint signs a token for the payload given by flags and/or a JSON file
+func mint(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("mint", stderr)
+	keyPath := fs.String("key", "", "private key file (PEM or JWK)")
+	payloadPath := fs.String("payload", "", "JSON file of claims to include, or - for stdin")
+	account := fs.String("account", "", "accountId claim")
+	realUser := fs.String("real-user", "", "realUserId claim")
+	effectiveUser := fs.String("effective-user", "", "effectiveUserId claim, defaults to the real user")
+	service := fs.String("service", "", "serviceName claim, minting a service token rather than a user token")
+	expiry := fs.Duration("expiry", 10*time.Minute, "how long the token is valid for")
+	issuer := fs.String("iss", "", "iss claim")
+	audience := fs.String("aud", "", "aud claim, comma separated for more than one")
+	subject := fs.String("sub", "", "sub claim")
+	kid := fs.String("kid", "", "kid header")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *keyPath == "" {
+		return fmt.Errorf("-key is required")
+	}
+
+	claims := jwt.Claims{}
+	if *payloadPath != "" {
+		var err error
+		if claims, err = readClaims(*payloadPath, stdin); err != nil {
+			return err
+		}
+	}
+
+	payload := payloadFromClaims(claims)
+	if *account != "" {
+		payload.Customer = *account
+	}
+	if *realUser != "" {
+		payload.RealUser = *realUser
+	}
+	if *effectiveUser != "" {
+		payload.EffectiveUser = *effectiveUser
+	}
+	if payload.EffectiveUser == "" {
+		payload.EffectiveUser = payload.RealUser
+	}
+	if *service != "" {
+		payload.Principal = jwt.PrincipalService
+		payload.Service = *service
+	}
+
+	opts := []jwt.EncodeOption{jwt.WithExpiry(*expiry)}
+	if *issuer != "" {
+		opts = append(opts, jwt.WithIssuerClaim(*issuer))
+	}
+	if *audience != "" {
+		opts = append(opts, jwt.WithAudienceClaim(strings.Split(*audience, ",")...))
+	}
+	if *subject != "" {
+		opts = append(opts, jwt.WithSubjectClaim(*subject))
+	}
+	if *kid != "" {
+		opts = append(opts, jwt.WithKeyID(*kid))
+	}
+
+	encoder, err := jwt.NewEncoderFromPath(*keyPath)
+	if err != nil {
+		return err
+	}
+	token, err := encoder.Encode(payload, opts...)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(stdout, token)
+	return err
+}
+
+// readClaims reads a JSON object of claims from 'path', or stdin if it is "-"
+func readClaims(path string, stdin io.Reader) (jwt.Claims, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(stdin)
+	} else {
+		data, err = ioutil.ReadFile(filepath.Clean(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.Claims{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse payload: %w", err)
+	}
+	return claims, nil
+}
+
+// payloadFromClaims takes the identity claims out of 'claims', leaving the
+// rest as extra claims
+func payloadFromClaims(claims jwt.Claims) jwt.Payload {
+	take := func(name string) string {
+		value, _ := claims.String(name)
+		delete(claims, name)
+		return value
+	}
+
+	payload := jwt.Payload{
+		Customer:      take("accountId"),
+		RealUser:      take("realUserId"),
+		EffectiveUser: take("effectiveUserId"),
+		Principal:     jwt.PrincipalType(take("principalType")),
+		Service:       take("serviceName"),
+	}
+	payload.Claims = claims
+	return payload
+}
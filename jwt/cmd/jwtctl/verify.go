@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cultureamp/gocampers/jwt"
+)
+
+// asymmetricAlgorithms are the algorithms accepted when verifying against a
+// JWKS, which can hold keys of any type
+var asymmetricAlgorithms = []string{"RS256", "ES256", "ES384", "ES512", "EdDSA"}
+
+// verify checks the signature and claims of a token, printing its claims if
+// it is valid or the reason it is not
+func verify(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("verify", stderr)
+	keyPath := fs.String("key", "", "public key file (PEM, JWK or X.509 certificate)")
+	jwksPath := fs.String("jwks", "", "JSON Web Key Set file")
+	issuer := fs.String("iss", "", "required iss claim")
+	audience := fs.String("aud", "", "required aud claim, comma separated to accept any of several")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	token, err := readToken(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+
+	opts := []jwt.DecoderOption{jwt.WithPrincipalTypes(jwt.PrincipalUser, jwt.PrincipalService)}
+	if *issuer != "" {
+		opts = append(opts, jwt.WithIssuer(*issuer))
+	}
+	if *audience != "" {
+		opts = append(opts, jwt.WithAudience(strings.Split(*audience, ",")...))
+	}
+
+	var decoder jwt.Decoder
+	switch {
+	case *keyPath != "" && *jwksPath != "":
+		return fmt.Errorf("only one of -key and -jwks can be given")
+	case *keyPath != "":
+		decoder, err = jwt.NewDecoderFromPath(*keyPath, opts...)
+	case *jwksPath != "":
+		decoder, err = jwksDecoder(*jwksPath, opts)
+	default:
+		return fmt.Errorf("-key or -jwks is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	payload, err := decoder.Decode(token)
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	if _, err := fmt.Fprintln(stdout, "token is valid"); err != nil {
+		return err
+	}
+	return printJSON(stdout, "claims", payload.Claims)
+}
+
+// jwksDecoder creates a Decoder for the JSON Web Key Set in the file at
+// 'path'. The key of a set holding a single key is also used for tokens
+// without a "kid", and that key doesn't need a "kid" of its own.
+func jwksDecoder(path string, opts []jwt.DecoderOption) (jwt.Decoder, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return jwt.Decoder{}, err
+	}
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return jwt.Decoder{}, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	fallbackKid := ""
+	if len(set.Keys) == 1 {
+		var key struct {
+			Kid string `json:"kid"`
+		}
+		if err := json.Unmarshal(set.Keys[0], &key); err != nil {
+			return jwt.Decoder{}, fmt.Errorf("failed to parse jwks: %w", err)
+		}
+		if key.Kid == "" {
+			return jwt.NewDecoderFromJWK(set.Keys[0], opts...)
+		}
+		fallbackKid = key.Kid
+	}
+
+	keys, err := jwt.NewStaticKeySetFromJSON(path, fallbackKid)
+	if err != nil {
+		return jwt.Decoder{}, err
+	}
+	return jwt.NewDecoderFromKeySet(keys, append([]jwt.DecoderOption{jwt.WithAllowedAlgorithms(asymmetricAlgorithms...)}, opts...)...), nil
+}
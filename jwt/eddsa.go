@@ -0,0 +1,58 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-errors/errors"
+)
+
+// SigningMethodEdDSA implements the EdDSA signing method (RFC 8037) for
+// Ed25519 keys, which jwt-go does not support out of the box. It expects an
+// ed25519.PrivateKey for signing and an ed25519.PublicKey for verification.
+var SigningMethodEdDSA jwtgo.SigningMethod = signingMethodEdDSA{}
+
+func init() {
+	jwtgo.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwtgo.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+type signingMethodEdDSA struct{}
+
+func (m signingMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	pubKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwtgo.ErrInvalidKeyType
+	}
+	if len(pubKey) != ed25519.PublicKeySize {
+		return jwtgo.ErrInvalidKey
+	}
+
+	sig, err := jwtgo.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(pubKey, []byte(signingString), sig) {
+		return errors.New("ed25519: verification error")
+	}
+
+	return nil
+}
+
+func (m signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	priKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwtgo.ErrInvalidKeyType
+	}
+	if len(priKey) != ed25519.PrivateKeySize {
+		return "", jwtgo.ErrInvalidKey
+	}
+
+	return jwtgo.EncodeSegment(ed25519.Sign(priKey, []byte(signingString))), nil
+}
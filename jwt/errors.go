@@ -0,0 +1,132 @@
+package jwt
+
+import (
+	"fmt"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-errors/errors"
+)
+
+// Errors returned when extracting and decoding a token. Use errors.Is to test
+// for these, as they are usually wrapped with more detail.
+var (
+	// ErrNoAuthorizationHeader is returned when the request has no Authorization header
+	ErrNoAuthorizationHeader = errors.New("missing authorization header")
+	// ErrNotBearer is returned when the Authorization header is not a Bearer token
+	ErrNotBearer = errors.New("missing 'Bearer' token in authorization header")
+	// ErrNoToken is returned when a TokenExtractor other than a header finds no
+	// token in the request, eg. the cookie or query parameter is not set
+	ErrNoToken = errors.New("missing token in request")
+	// ErrKeyNotFound is returned when a KeySource has no key to load, eg. the
+	// environment variable is not set or the file does not exist
+	ErrKeyNotFound = errors.New("jwt key not found")
+	// ErrMalformed is returned when the token can't be parsed
+	ErrMalformed = errors.New("malformed jwt token")
+	// ErrAlgorithmNotAllowed is returned when the token is signed with an
+	// algorithm the Decoder does not accept
+	ErrAlgorithmNotAllowed = errors.New("jwt signing algorithm is not allowed")
+	// ErrMissingKeyID is returned when the token has no "kid" header and the
+	// Decoder can't pick a key without one
+	ErrMissingKeyID = errors.New("missing 'kid' in jwt header")
+	// ErrCertificateInvalid is returned when the certificate holding the
+	// verification key has expired or is not signed by a trusted CA
+	ErrCertificateInvalid = errors.New("jwt certificate is invalid")
+	// ErrDecryptionFailed is returned when an encrypted token can't be decrypted
+	ErrDecryptionFailed = errors.New("jwt decryption failed")
+	// ErrSignatureInvalid is returned when the token signature does not verify
+	ErrSignatureInvalid = errors.New("jwt signature is invalid")
+	// ErrTokenExpired is returned when the "exp" claim is in the past
+	ErrTokenExpired = errors.New("token is expired")
+	// ErrTokenNotValidYet is returned when the "nbf" claim is in the future
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	// ErrTokenUsedBeforeIssued is returned when the "iat" claim is in the future
+	ErrTokenUsedBeforeIssued = errors.New("token used before issued")
+	// ErrTokenRevoked is returned when the token has been revoked before it expired
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrAccountKeyMismatch is returned when the account in the "accountId"
+	// claim has a key of its own, but the token is not signed with it
+	ErrAccountKeyMismatch = errors.New("jwt not signed with the key of its account")
+	// ErrRefreshTokenReused is returned when a refresh token that has already
+	// been exchanged is used again, or belongs to a revoked family
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+)
+
+// ErrMissingClaim is returned when a claim the Decoder requires is not in the token
+type ErrMissingClaim struct {
+	Name string
+}
+
+func (e ErrMissingClaim) Error() string {
+	return fmt.Sprintf("missing %s in jwt token", e.Name)
+}
+
+// Is reports whether target is an ErrMissingClaim for the same claim. An
+// ErrMissingClaim with an empty Name matches any missing claim.
+func (e ErrMissingClaim) Is(target error) bool {
+	t, ok := target.(ErrMissingClaim)
+	return ok && (t.Name == "" || t.Name == e.Name)
+}
+
+// ErrInvalidClaim is returned when a claim is present but fails validation,
+// eg. the "exp" claim of an expired token or an unexpected "iss"
+type ErrInvalidClaim struct {
+	Name string
+	Err  error
+}
+
+func (e ErrInvalidClaim) Error() string {
+	return fmt.Sprintf("invalid '%s' claim in jwt token: %s", e.Name, e.Err)
+}
+
+// Unwrap returns the reason the claim is invalid, eg. ErrTokenExpired
+func (e ErrInvalidClaim) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an ErrInvalidClaim for the same claim. An
+// ErrInvalidClaim with an empty Name matches any invalid claim.
+func (e ErrInvalidClaim) Is(target error) bool {
+	t, ok := target.(ErrInvalidClaim)
+	return ok && (t.Name == "" || t.Name == e.Name)
+}
+
+// ErrUnknownKeyID is returned when the "kid" header of a token does not match
+// any key known to the Decoder
+type ErrUnknownKeyID struct {
+	Kid string
+}
+
+func (e ErrUnknownKeyID) Error() string {
+	return fmt.Sprintf("unknown 'kid' %s in jwt header", e.Kid)
+}
+
+// Is reports whether target is an ErrUnknownKeyID for the same kid. An
+// ErrUnknownKeyID with an empty Kid matches any unknown kid.
+func (e ErrUnknownKeyID) Is(target error) bool {
+	t, ok := target.(ErrUnknownKeyID)
+	return ok && (t.Kid == "" || t.Kid == e.Kid)
+}
+
+// translateParseError maps the errors returned by jwt-go onto the errors
+// exported by this package
+func translateParseError(err error) error {
+	ve, ok := err.(*jwtgo.ValidationError)
+	if !ok {
+		return err
+	}
+
+	switch {
+	case ve.Errors&jwtgo.ValidationErrorMalformed != 0:
+		return fmt.Errorf("%w: %s", ErrMalformed, ve.Error())
+	case ve.Errors&jwtgo.ValidationErrorUnverifiable != 0:
+		if ve.Inner != nil {
+			// an error from keyFunc, which is already one of ours
+			return ve.Inner
+		}
+		return fmt.Errorf("%w: %s", ErrMalformed, ve.Error())
+	case ve.Errors&jwtgo.ValidationErrorSignatureInvalid != 0:
+		return fmt.Errorf("%w: %s", ErrSignatureInvalid, ve.Error())
+	default:
+		return err
+	}
+}
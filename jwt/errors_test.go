@@ -0,0 +1,84 @@
+package jwt
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Errors_Decode(t *testing.T) {
+	priKey, _ := devKeys(t)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	valid := signClaims(t, priKey, nil)
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2]))
+
+	missingClaim, err := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, jwtgo.MapClaims{
+		"accountId":  "abc123",
+		"realUserId": "xyz234",
+	}).SignedString(priKey)
+	require.Nil(t, err)
+
+	cases := map[string]struct {
+		token    string
+		expected error
+	}{
+		"expired":       {signClaims(t, priKey, jwtgo.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), ErrTokenExpired},
+		"not valid yet": {signClaims(t, priKey, jwtgo.MapClaims{"nbf": time.Now().Add(time.Minute).Unix()}), ErrTokenNotValidYet},
+		"signature":     {tampered, ErrSignatureInvalid},
+		"malformed":     {"INVALID.TOKEN.", ErrMalformed},
+		"segments":      {"not-a-jwt", ErrMalformed},
+		"missing claim": {missingClaim, ErrMissingClaim{Name: "effectiveUserId"}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := decoder.Decode(c.token)
+			assert.NotNil(t, err)
+			assert.True(t, errors.Is(err, c.expected), err.Error())
+		})
+	}
+}
+
+func Test_Errors_As(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	decoder := NewDecoderFromKeySet(NewStaticKeySet(map[string]interface{}{"2021": pubKey}, nil))
+
+	_, err := decoder.Decode(signWithKid(t, priKey, "2019"))
+	var unknownKid ErrUnknownKeyID
+	assert.True(t, errors.As(err, &unknownKid))
+	assert.Equal(t, "2019", unknownKid.Kid)
+
+	_, err = decoder.Decode(signWithKid(t, priKey, ""))
+	assert.True(t, errors.Is(err, ErrMissingKeyID))
+
+	decoder, err = NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+	_, err = decoder.Decode(signClaims(t, priKey, jwtgo.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}))
+	var invalidClaim ErrInvalidClaim
+	assert.True(t, errors.As(err, &invalidClaim))
+	assert.Equal(t, "exp", invalidClaim.Name)
+	assert.True(t, errors.Is(err, ErrInvalidClaim{}))
+	assert.False(t, errors.Is(err, ErrInvalidClaim{Name: "nbf"}))
+}
+
+func Test_Errors_PayloadFromRequest(t *testing.T) {
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	_, err = PayloadFromRequest(req, decoder)
+	assert.True(t, errors.Is(err, ErrNoAuthorizationHeader))
+
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	_, err = PayloadFromRequest(req, decoder)
+	assert.True(t, errors.Is(err, ErrNotBearer))
+}
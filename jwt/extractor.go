@@ -0,0 +1,213 @@
+package jwt
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-errors/errors"
+)
+
+// AuthorizationHeader is the header a bearer token is sent in by default
+const AuthorizationHeader = "Authorization"
+
+// AccessTokenParameter is the query parameter a bearer token is sent in when
+// it can't be sent in a header (RFC 6750 section 2.3)
+const AccessTokenParameter = "access_token"
+
+// TokenExtractor finds the token in a request
+type TokenExtractor interface {
+	// Extract returns the token in 'r'. It returns an error matching
+	// ErrNoAuthorizationHeader or ErrNoToken if the request has no token, and
+	// ErrNotBearer if a token is present but not usable.
+	Extract(r *http.Request) (string, error)
+}
+
+// TokenExtractorFunc is a TokenExtractor calling a function to find the token
+type TokenExtractorFunc func(r *http.Request) (string, error)
+
+// Extract calls f
+func (f TokenExtractorFunc) Extract(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// HeaderExtractor is a TokenExtractor reading a bearer token from a header, as
+// described by RFC 6750 section 2.1: the "Bearer" scheme, matched regardless
+// of case, followed by the token.
+type HeaderExtractor struct {
+	name string
+}
+
+// NewHeaderExtractor creates a TokenExtractor reading a bearer token from the
+// header 'name', eg. AuthorizationHeader or a custom header such as
+// "X-CA-SGW-Authorization"
+func NewHeaderExtractor(name string) HeaderExtractor {
+	return HeaderExtractor{name: name}
+}
+
+// Extract returns the bearer token in the header
+func (e HeaderExtractor) Extract(r *http.Request) (string, error) {
+	header := r.Header.Get(e.name) // "Authorization: Bearer xxxxx.yyyyy.zzzzz"
+	if header == "" && e.name != AuthorizationHeader {
+		return "", fmt.Errorf("%w: %s", ErrNoAuthorizationHeader, e.name)
+	}
+
+	return BearerToken(header)
+}
+
+// BearerToken returns the token in the Authorization header value 'header',
+// eg. from gRPC metadata. It returns ErrNoAuthorizationHeader if 'header' is
+// empty, and ErrNotBearer if it is not an RFC 6750 bearer token.
+func BearerToken(header string) (string, error) {
+	if header == "" {
+		return "", ErrNoAuthorizationHeader
+	}
+
+	scheme, token, ok := cutScheme(header)
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrNotBearer
+	}
+	if !isB64Token(token) {
+		return "", fmt.Errorf("%w: token contains invalid characters", ErrNotBearer)
+	}
+
+	return token, nil
+}
+
+// CookieExtractor is a TokenExtractor reading the token from a cookie
+type CookieExtractor struct {
+	name string
+}
+
+// NewCookieExtractor creates a TokenExtractor reading the token from the
+// cookie 'name'
+func NewCookieExtractor(name string) CookieExtractor {
+	return CookieExtractor{name: name}
+}
+
+// Extract returns the value of the cookie
+func (e CookieExtractor) Extract(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(e.name)
+	if err != nil || cookie.Value == "" {
+		return "", fmt.Errorf("%w: no cookie %s", ErrNoToken, e.name)
+	}
+
+	return cookie.Value, nil
+}
+
+// QueryExtractor is a TokenExtractor reading the token from a query parameter.
+// Tokens in URLs are easily leaked through logs and the Referer header, so
+// this should only be used where a header or cookie can't be, eg. for
+// WebSocket or EventSource connections from a browser.
+type QueryExtractor struct {
+	name string
+}
+
+// NewQueryExtractor creates a TokenExtractor reading the token from the query
+// parameter 'name', usually AccessTokenParameter
+func NewQueryExtractor(name string) QueryExtractor {
+	return QueryExtractor{name: name}
+}
+
+// Extract returns the value of the query parameter
+func (e QueryExtractor) Extract(r *http.Request) (string, error) {
+	token := r.URL.Query().Get(e.name)
+	if token == "" {
+		return "", fmt.Errorf("%w: no query parameter %s", ErrNoToken, e.name)
+	}
+	if !isB64Token(token) {
+		return "", fmt.Errorf("%w: token contains invalid characters", ErrNotBearer)
+	}
+
+	return token, nil
+}
+
+// ExtractorChain is a TokenExtractor returning the token from the first of its
+// extractors to find one
+type ExtractorChain []TokenExtractor
+
+// NewExtractorChain creates a TokenExtractor trying each of 'extractors' in
+// order, eg. the Authorization header and then a session cookie
+func NewExtractorChain(extractors ...TokenExtractor) ExtractorChain {
+	return ExtractorChain(extractors)
+}
+
+// Extract returns the token from the first extractor to find one. If none do,
+// an unusable token (ErrNotBearer) is reported in preference to a missing one,
+// so a malformed header isn't hidden by a missing cookie.
+func (c ExtractorChain) Extract(r *http.Request) (string, error) {
+	var missing, unusable error
+	for _, extractor := range c {
+		token, err := extractor.Extract(r)
+		switch {
+		case err == nil:
+			return token, nil
+		case isMissingToken(err):
+			if missing == nil {
+				missing = err
+			}
+		default:
+			if unusable == nil {
+				unusable = err
+			}
+		}
+	}
+
+	switch {
+	case unusable != nil:
+		return "", unusable
+	case missing != nil:
+		return "", missing
+	default:
+		return "", ErrNoToken
+	}
+}
+
+// tokenExtractor returns the TokenExtractor for 'extractors': the
+// Authorization header if there are none, or a chain of them
+func tokenExtractor(extractors []TokenExtractor) TokenExtractor {
+	switch len(extractors) {
+	case 0:
+		return NewHeaderExtractor(AuthorizationHeader)
+	case 1:
+		return extractors[0]
+	default:
+		return NewExtractorChain(extractors...)
+	}
+}
+
+// isMissingToken returns true if 'err' reports that a request has no token,
+// rather than an unusable one
+func isMissingToken(err error) bool {
+	return errors.Is(err, ErrNoToken) || errors.Is(err, ErrNoAuthorizationHeader)
+}
+
+// cutScheme splits an Authorization header value into its scheme and
+// credentials, separated by one or more spaces
+func cutScheme(header string) (string, string, bool) {
+	i := strings.IndexByte(header, ' ')
+	if i <= 0 {
+		return "", "", false
+	}
+
+	return header[:i], strings.TrimLeft(header[i+1:], " "), true
+}
+
+// isB64Token returns true if 's' is a valid RFC 6750 b64token:
+// 1*( ALPHA / DIGIT / "-" / "." / "_" / "~" / "+" / "/" ) *"="
+func isB64Token(s string) bool {
+	body := strings.TrimRight(s, "=")
+	if body == "" {
+		return false
+	}
+
+	for _, c := range body {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~', c == '+', c == '/':
+		default:
+			return false
+		}
+	}
+	return true
+}
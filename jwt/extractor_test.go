@@ -0,0 +1,166 @@
+package jwt
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Extractor_Header(t *testing.T) {
+	cases := map[string]struct {
+		header string
+		token  string
+		err    error
+	}{
+		"bearer":           {header: "Bearer abc.def.ghi", token: "abc.def.ghi"},
+		"lower case":       {header: "bearer abc.def.ghi", token: "abc.def.ghi"},
+		"upper case":       {header: "BEARER abc.def.ghi", token: "abc.def.ghi"},
+		"extra spaces":     {header: "Bearer   abc.def.ghi", token: "abc.def.ghi"},
+		"padding":          {header: "Bearer abc+/~_-==", token: "abc+/~_-=="},
+		"missing":          {err: ErrNoAuthorizationHeader},
+		"basic":            {header: "Basic dXNlcjpwYXNz", err: ErrNotBearer},
+		"no space":         {header: "BearerFoo", err: ErrNotBearer},
+		"no token":         {header: "Bearer ", err: ErrNotBearer},
+		"scheme only":      {header: "Bearer", err: ErrNotBearer},
+		"bearer elsewhere": {header: "Token Bearer abc", err: ErrNotBearer},
+		"invalid token":    {header: "Bearer abc def", err: ErrNotBearer},
+		"only padding":     {header: "Bearer ==", err: ErrNotBearer},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+			if c.header != "" {
+				req.Header.Set("Authorization", c.header)
+			}
+
+			token, err := NewHeaderExtractor(AuthorizationHeader).Extract(req)
+			if c.err != nil {
+				assert.True(t, errors.Is(err, c.err), "got %v", err)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, c.token, token)
+		})
+	}
+}
+
+func Test_Extractor_CustomHeader(t *testing.T) {
+	extractor := NewHeaderExtractor("X-CA-SGW-Authorization")
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Authorization", "Bearer ignored")
+	_, err := extractor.Extract(req)
+	assert.True(t, errors.Is(err, ErrNoAuthorizationHeader))
+	assert.Contains(t, err.Error(), "X-CA-SGW-Authorization")
+
+	req.Header.Set("X-CA-SGW-Authorization", "Bearer abc.def.ghi")
+	token, err := extractor.Extract(req)
+	require.Nil(t, err)
+	assert.Equal(t, "abc.def.ghi", token)
+}
+
+func Test_Extractor_Cookie(t *testing.T) {
+	extractor := NewCookieExtractor("session")
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	_, err := extractor.Extract(req)
+	assert.True(t, errors.Is(err, ErrNoToken))
+
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc.def.ghi"})
+	token, err := extractor.Extract(req)
+	require.Nil(t, err)
+	assert.Equal(t, "abc.def.ghi", token)
+}
+
+func Test_Extractor_Query(t *testing.T) {
+	extractor := NewQueryExtractor(AccessTokenParameter)
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	_, err := extractor.Extract(req)
+	assert.True(t, errors.Is(err, ErrNoToken))
+
+	req = httptest.NewRequest("GET", "http://example.com/foo?access_token=abc.def.ghi", nil)
+	token, err := extractor.Extract(req)
+	require.Nil(t, err)
+	assert.Equal(t, "abc.def.ghi", token)
+
+	req = httptest.NewRequest("GET", "http://example.com/foo?access_token=abc%20def", nil)
+	_, err = extractor.Extract(req)
+	assert.True(t, errors.Is(err, ErrNotBearer))
+}
+
+func Test_Extractor_Chain(t *testing.T) {
+	chain := NewExtractorChain(
+		NewHeaderExtractor(AuthorizationHeader),
+		NewCookieExtractor("session"),
+		NewQueryExtractor(AccessTokenParameter),
+	)
+
+	req := httptest.NewRequest("GET", "http://example.com/foo?access_token=from.query", nil)
+	token, err := chain.Extract(req)
+	require.Nil(t, err)
+	assert.Equal(t, "from.query", token)
+
+	req.AddCookie(&http.Cookie{Name: "session", Value: "from.cookie"})
+	token, err = chain.Extract(req)
+	require.Nil(t, err)
+	assert.Equal(t, "from.cookie", token)
+
+	req.Header.Set("Authorization", "Bearer from.header")
+	token, err = chain.Extract(req)
+	require.Nil(t, err)
+	assert.Equal(t, "from.header", token)
+}
+
+func Test_Extractor_ChainErrors(t *testing.T) {
+	chain := NewExtractorChain(
+		NewHeaderExtractor(AuthorizationHeader),
+		NewCookieExtractor("session"),
+	)
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	_, err := chain.Extract(req)
+	assert.True(t, errors.Is(err, ErrNoAuthorizationHeader))
+
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	_, err = chain.Extract(req)
+	assert.True(t, errors.Is(err, ErrNotBearer))
+
+	req.AddCookie(&http.Cookie{Name: "session", Value: "from.cookie"})
+	token, err := chain.Extract(req)
+	require.Nil(t, err)
+	assert.Equal(t, "from.cookie", token)
+
+	_, err = NewExtractorChain().Extract(req)
+	assert.True(t, errors.Is(err, ErrNoToken))
+}
+
+func Test_PayloadFromRequest_Extractors(t *testing.T) {
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Authorization", "bearer "+token)
+	payload, err := PayloadFromRequest(req, decoder)
+	require.Nil(t, err)
+	assert.Equal(t, "abc123", payload.Customer)
+
+	req = httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: token})
+	_, err = PayloadFromRequest(req, decoder)
+	assert.True(t, errors.Is(err, ErrNoAuthorizationHeader))
+
+	payload, err = PayloadFromRequest(req, decoder, NewHeaderExtractor(AuthorizationHeader), NewCookieExtractor("session"))
+	require.Nil(t, err)
+	assert.Equal(t, "xyz345", payload.EffectiveUser)
+}
@@ -0,0 +1,43 @@
+package jwt
+
+import (
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+)
+
+// ImpersonationStartedAtClaim records when the real user started acting as
+// the effective user, so impersonation sessions can be time limited
+const ImpersonationStartedAtClaim = "impersonationStartedAt"
+
+// IsImpersonating returns true if a user is acting as another user, ie. the
+// real user is not the effective user
+func (p Payload) IsImpersonating() bool {
+	return p.IsUser() && p.RealUser != "" && p.EffectiveUser != "" && p.RealUser != p.EffectiveUser
+}
+
+// ImpersonationStartedAt returns when the real user started acting as the
+// effective user, from the "impersonationStartedAt" claim or the "iat" claim
+// if the token does not have one. It returns false if the Payload is not
+// impersonating or neither claim is present.
+func (p Payload) ImpersonationStartedAt() (time.Time, bool) {
+	if !p.IsImpersonating() {
+		return time.Time{}, false
+	}
+
+	for _, name := range []string{ImpersonationStartedAtClaim, "iat"} {
+		if started, ok, err := timeClaim(jwtgo.MapClaims(p.Claims), name); err == nil && ok {
+			return started, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// WithImpersonationStartedAt sets the "impersonationStartedAt" claim on tokens
+// where the real user is acting as another user. It is carried over when the
+// token is refreshed, so the session can be limited as a whole.
+func WithImpersonationStartedAt(started time.Time) EncodeOption {
+	return func(c *encodeConfig) {
+		c.impersonationStartedAt = started
+	}
+}
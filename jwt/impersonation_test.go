@@ -0,0 +1,61 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Impersonation_IsImpersonating(t *testing.T) {
+	cases := map[string]struct {
+		payload  Payload
+		expected bool
+	}{
+		"same user":      {Payload{RealUser: "xyz234", EffectiveUser: "xyz234"}, false},
+		"different user": {Payload{RealUser: "xyz234", EffectiveUser: "xyz345"}, true},
+		"missing user":   {Payload{RealUser: "xyz234"}, false},
+		"service":        {Payload{Principal: PrincipalService, Service: "worker", RealUser: "xyz234", EffectiveUser: "xyz345"}, false},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, c.expected, c.payload.IsImpersonating())
+		})
+	}
+}
+
+func Test_Impersonation_StartedAt(t *testing.T) {
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+	started := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"}, WithImpersonationStartedAt(started))
+	require.Nil(t, err)
+	payload, err := decoder.Decode(token)
+	require.Nil(t, err)
+	at, ok := payload.ImpersonationStartedAt()
+	assert.True(t, ok)
+	assert.True(t, started.Equal(at))
+
+	// falls back to when the token was issued
+	token, err = encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+	payload, err = decoder.Decode(token)
+	require.Nil(t, err)
+	at, ok = payload.ImpersonationStartedAt()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now(), at, time.Minute)
+
+	// only set when impersonating
+	token, err = encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz234"}, WithImpersonationStartedAt(started))
+	require.Nil(t, err)
+	payload, err = decoder.Decode(token)
+	require.Nil(t, err)
+	assert.NotContains(t, payload.Claims, ImpersonationStartedAtClaim)
+	_, ok = payload.ImpersonationStartedAt()
+	assert.False(t, ok)
+}
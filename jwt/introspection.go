@@ -0,0 +1,202 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-errors/errors"
+)
+
+// IntrospectionAuthenticator authenticates the clients calling a token
+// introspection endpoint. RFC 7662 requires the endpoint to be protected so it
+// can't be used to probe for valid tokens.
+type IntrospectionAuthenticator interface {
+	// Authenticate returns an error if 'r' is not from an authorised client
+	Authenticate(r *http.Request) error
+}
+
+// IntrospectionAuthenticatorFunc is an IntrospectionAuthenticator calling a
+// function to authenticate the client, eg. to check a client certificate
+type IntrospectionAuthenticatorFunc func(r *http.Request) error
+
+// Authenticate calls f
+func (f IntrospectionAuthenticatorFunc) Authenticate(r *http.Request) error {
+	return f(r)
+}
+
+// AnyIntrospectionClient is an IntrospectionAuthenticator accepting every
+// client. Only use it where the endpoint is otherwise protected, eg. it is
+// only reachable inside a service mesh that authenticates callers.
+var AnyIntrospectionClient = IntrospectionAuthenticatorFunc(func(r *http.Request) error {
+	return nil
+})
+
+// noIntrospectionClients rejects every client, so a handler without an
+// IntrospectionAuthenticator fails closed
+var noIntrospectionClients = IntrospectionAuthenticatorFunc(func(r *http.Request) error {
+	return errors.New("no introspection clients configured")
+})
+
+// BasicAuthClients is an IntrospectionAuthenticator accepting clients
+// authenticating with HTTP Basic authentication (RFC 6749 section 2.3.1). It
+// maps each client id to its secret.
+type BasicAuthClients map[string]string
+
+// Authenticate checks the client id and secret in the Authorization header
+func (c BasicAuthClients) Authenticate(r *http.Request) error {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		return errors.New("missing client credentials")
+	}
+	// RFC 6749 form-encodes the credentials before base64 encoding them
+	if unescaped, err := url.QueryUnescape(id); err == nil {
+		id = unescaped
+	}
+	if unescaped, err := url.QueryUnescape(secret); err == nil {
+		secret = unescaped
+	}
+
+	expected, known := c[id]
+	// compare hashes so the time taken reveals nothing about the secret,
+	// including its length, and unknown clients take as long as known ones
+	want := sha256.Sum256([]byte(expected))
+	got := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(want[:], got[:]) != 1 || !known {
+		return errors.New("invalid client credentials")
+	}
+
+	return nil
+}
+
+// introspectionResponse is the RFC 7662 section 2.2 response. An inactive
+// token only has Active set.
+type introspectionResponse struct {
+	Active    bool        `json:"active"`
+	Scope     string      `json:"scope,omitempty"`
+	TokenType string      `json:"token_type,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Issuer    string      `json:"iss,omitempty"`
+	Audience  interface{} `json:"aud,omitempty"`
+	ID        string      `json:"jti,omitempty"`
+	Expiry    int64       `json:"exp,omitempty"`
+	IssuedAt  int64       `json:"iat,omitempty"`
+	NotBefore int64       `json:"nbf,omitempty"`
+
+	// identity claims, named as they are in the token
+	AccountID       string        `json:"accountId,omitempty"`
+	RealUserID      string        `json:"realUserId,omitempty"`
+	EffectiveUserID string        `json:"effectiveUserId,omitempty"`
+	PrincipalType   PrincipalType `json:"principalType,omitempty"`
+	ServiceName     string        `json:"serviceName,omitempty"`
+}
+
+// introspectionError is the RFC 6749 section 5.2 error response
+type introspectionError struct {
+	Error string `json:"error"`
+}
+
+type introspectionHandler struct {
+	decoder DecodeJwtToken
+	clients IntrospectionAuthenticator
+}
+
+// NewIntrospectionHandler creates an http.Handler implementing the OAuth 2.0
+// token introspection endpoint (RFC 7662) with 'decoder', so services that
+// can't use this package can check tokens. Clients POST the form encoded
+// "token" and are told whether it is active, with its identity and standard
+// claims if it is. Callers are authenticated with 'clients'; if it is nil
+// every caller is rejected.
+func NewIntrospectionHandler(decoder DecodeJwtToken, clients IntrospectionAuthenticator) http.Handler {
+	if clients == nil {
+		clients = noIntrospectionClients
+	}
+
+	return introspectionHandler{
+		decoder: decoder,
+		clients: clients,
+	}
+}
+
+func (h introspectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeIntrospectionJSON(w, http.StatusMethodNotAllowed, introspectionError{Error: "invalid_request"})
+		return
+	}
+
+	if err := h.clients.Authenticate(r); err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+		writeIntrospectionJSON(w, http.StatusUnauthorized, introspectionError{Error: "invalid_client"})
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		writeIntrospectionJSON(w, http.StatusBadRequest, introspectionError{Error: "invalid_request"})
+		return
+	}
+
+	// the reason a token is inactive is deliberately not disclosed
+	payload, err := h.decoder.Decode(token)
+	if err != nil {
+		writeIntrospectionJSON(w, http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+
+	writeIntrospectionJSON(w, http.StatusOK, newIntrospectionResponse(payload))
+}
+
+// newIntrospectionResponse maps the claims of an active token onto the
+// introspection response
+func newIntrospectionResponse(payload Payload) introspectionResponse {
+	claims := jwtgo.MapClaims(payload.Claims)
+
+	resp := introspectionResponse{
+		Active:          true,
+		Scope:           strings.Join(payload.Scopes, " "),
+		TokenType:       "Bearer",
+		AccountID:       payload.Customer,
+		RealUserID:      payload.RealUser,
+		EffectiveUserID: payload.EffectiveUser,
+		ServiceName:     payload.Service,
+	}
+	if payload.IsService() {
+		resp.PrincipalType = PrincipalService
+	}
+
+	resp.Subject, _ = payload.Claims.String("sub")
+	resp.Issuer, _ = payload.Claims.String("iss")
+	resp.ID, _ = payload.Claims.String("jti")
+	switch aud := audienceClaim(claims); len(aud) {
+	case 0:
+	case 1:
+		resp.Audience = aud[0]
+	default:
+		resp.Audience = aud
+	}
+	// the decoder has already rejected malformed time claims
+	if exp, ok, _ := timeClaim(claims, "exp"); ok {
+		resp.Expiry = exp.Unix()
+	}
+	if iat, ok, _ := timeClaim(claims, "iat"); ok {
+		resp.IssuedAt = iat.Unix()
+	}
+	if nbf, ok, _ := timeClaim(claims, "nbf"); ok {
+		resp.NotBefore = nbf.Unix()
+	}
+
+	return resp
+}
+
+func writeIntrospectionJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	// introspection responses describe credentials, so must not be cached
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
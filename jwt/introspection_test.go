@@ -0,0 +1,167 @@
+package jwt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Introspection_ActiveToken(t *testing.T) {
+	encoder, decoder := introspectionKeys(t)
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"},
+		WithIssuerClaim("identity-api"),
+		WithAudienceClaim("murmur"),
+		WithIDClaim("token-1"),
+	)
+	require.Nil(t, err)
+
+	resp := introspect(t, NewIntrospectionHandler(decoder, AnyIntrospectionClient), token, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+
+	var body map[string]interface{}
+	require.Nil(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, true, body["active"])
+	assert.Equal(t, "Bearer", body["token_type"])
+	assert.Equal(t, "abc123", body["accountId"])
+	assert.Equal(t, "xyz234", body["realUserId"])
+	assert.Equal(t, "xyz345", body["effectiveUserId"])
+	assert.Equal(t, "identity-api", body["iss"])
+	assert.Equal(t, "murmur", body["aud"])
+	assert.Equal(t, "token-1", body["jti"])
+	assert.InDelta(t, time.Now().Unix(), body["iat"], 5)
+	assert.InDelta(t, time.Now().Add(defaultExpiry).Unix(), body["exp"], 5)
+	assert.NotContains(t, body, "principalType")
+}
+
+func Test_Introspection_ServiceToken(t *testing.T) {
+	encoder, decoder := introspectionKeys(t, WithPrincipalTypes(PrincipalUser, PrincipalService))
+	token, err := encoder.Encode(Payload{Principal: PrincipalService, Service: "murmur-worker"})
+	require.Nil(t, err)
+
+	resp := introspect(t, NewIntrospectionHandler(decoder, AnyIntrospectionClient), token, nil)
+
+	var body map[string]interface{}
+	require.Nil(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, true, body["active"])
+	assert.Equal(t, "service", body["principalType"])
+	assert.Equal(t, "murmur-worker", body["serviceName"])
+	assert.NotContains(t, body, "realUserId")
+}
+
+func Test_Introspection_InactiveToken(t *testing.T) {
+	encoder, decoder := introspectionKeys(t)
+	expired, err := encoder.EncodeWithExpiry(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"}, -time.Minute)
+	require.Nil(t, err)
+
+	handler := NewIntrospectionHandler(decoder, AnyIntrospectionClient)
+	for _, token := range []string{expired, "INVALID.TOKEN."} {
+		resp := introspect(t, handler, token, nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, `{"active":false}`, resp.Body.String())
+	}
+}
+
+func Test_Introspection_BadRequest(t *testing.T) {
+	_, decoder := introspectionKeys(t)
+	handler := NewIntrospectionHandler(decoder, AnyIntrospectionClient)
+
+	resp := introspect(t, handler, "", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.JSONEq(t, `{"error":"invalid_request"}`, resp.Body.String())
+
+	req := httptest.NewRequest("GET", "/introspect?token=abc", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "POST", rec.Header().Get("Allow"))
+}
+
+func Test_Introspection_ClientAuthentication(t *testing.T) {
+	encoder, decoder := introspectionKeys(t)
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	handler := NewIntrospectionHandler(decoder, BasicAuthClients{"reporting": "s3cret:/+"})
+
+	cases := map[string]struct {
+		setAuth func(r *http.Request)
+		status  int
+	}{
+		"valid": {
+			setAuth: func(r *http.Request) { r.SetBasicAuth("reporting", url.QueryEscape("s3cret:/+")) },
+			status:  http.StatusOK,
+		},
+		"wrong secret": {
+			setAuth: func(r *http.Request) { r.SetBasicAuth("reporting", "wrong") },
+			status:  http.StatusUnauthorized,
+		},
+		"unknown client": {
+			setAuth: func(r *http.Request) { r.SetBasicAuth("unknown", "") },
+			status:  http.StatusUnauthorized,
+		},
+		"no credentials": {
+			setAuth: func(r *http.Request) {},
+			status:  http.StatusUnauthorized,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			resp := introspect(t, handler, token, c.setAuth)
+			assert.Equal(t, c.status, resp.Code)
+			if c.status == http.StatusUnauthorized {
+				assert.JSONEq(t, `{"error":"invalid_client"}`, resp.Body.String())
+				assert.NotEmpty(t, resp.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func Test_Introspection_NilClients(t *testing.T) {
+	encoder, decoder := introspectionKeys(t)
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	resp := introspect(t, NewIntrospectionHandler(decoder, nil), token, func(r *http.Request) {
+		r.SetBasicAuth("reporting", "s3cret")
+	})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.JSONEq(t, `{"error":"invalid_client"}`, resp.Body.String())
+}
+
+func introspectionKeys(t *testing.T, opts ...DecoderOption) (Encoder, Decoder) {
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", opts...)
+	require.Nil(t, err)
+
+	return encoder, decoder
+}
+
+// introspect POSTs 'token' to 'handler', calling 'setAuth' to authenticate
+// the request if it is not nil
+func introspect(t *testing.T, handler http.Handler, token string, setAuth func(r *http.Request)) *httptest.ResponseRecorder {
+	form := url.Values{}
+	if token != "" {
+		form.Set("token", token)
+	}
+
+	req := httptest.NewRequest("POST", "/introspect", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if setAuth != nil {
+		setAuth(req)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
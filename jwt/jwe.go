@@ -0,0 +1,152 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" // RSA-OAEP is defined with SHA-1 by RFC 7518
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The JWE algorithms used for encrypted tokens (RFC 7518): the content key is
+// encrypted with RSA-OAEP and the signed token with AES-256-GCM
+const (
+	jweAlgorithm  = "RSA-OAEP"
+	jweEncryption = "A256GCM"
+)
+
+// jweHeader is the protected header of an encrypted token
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Cty string `json:"cty,omitempty"`
+	Zip string `json:"zip,omitempty"`
+}
+
+// WithEncryption encrypts the signed token for the holder of the private
+// half of 'key', so its claims can't be read by the browser or anyone else
+// passing it along. The result is a nested JWT (RFC 7519 section 5.2): a JWS
+// inside a JWE using RSA-OAEP and A256GCM.
+func WithEncryption(key *rsa.PublicKey) EncodeOption {
+	return func(c *encodeConfig) {
+		c.encryptionKey = key
+	}
+}
+
+// WithDecryptionKey lets the Decoder decrypt tokens encrypted with
+// WithEncryption, before verifying the signed token inside. Tokens that are
+// only signed are still accepted.
+func WithDecryptionKey(key *rsa.PrivateKey) DecoderOption {
+	return func(d *Decoder) {
+		d.decryptionKey = key
+	}
+}
+
+// isEncrypted returns true if 'tokenString' is in the JWE compact serialization,
+// which has five parts rather than the three of a JWS
+func isEncrypted(tokenString string) bool {
+	return strings.Count(tokenString, ".") == 4
+}
+
+// encryptToken encrypts the signed token 'signed' for 'key'
+func encryptToken(signed string, key *rsa.PublicKey) (string, error) {
+	header, err := json.Marshal(jweHeader{Alg: jweAlgorithm, Enc: jweEncryption, Cty: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		return "", err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, key, cek, nil)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, []byte(signed), []byte(encodedHeader))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		encodedHeader,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// decryptToken decrypts the encrypted token 'tokenString' with 'key',
+// returning the signed token inside
+func decryptToken(tokenString string, key *rsa.PrivateKey) (string, error) {
+	if key == nil {
+		return "", fmt.Errorf("%w: no decryption key configured for jwt decoder", ErrDecryptionFailed)
+	}
+
+	parts := strings.Split(tokenString, ".")
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		b, err := base64.RawURLEncoding.DecodeString(part)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrMalformed, err)
+		}
+		decoded[i] = b
+	}
+
+	var header jweHeader
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrMalformed, err)
+	}
+	if header.Alg != jweAlgorithm || header.Enc != jweEncryption {
+		return "", fmt.Errorf("%w: unsupported jwe algorithm %s/%s", ErrDecryptionFailed, header.Alg, header.Enc)
+	}
+	if header.Zip != "" {
+		return "", fmt.Errorf("%w: unsupported jwe compression %s", ErrDecryptionFailed, header.Zip)
+	}
+	if header.Cty != "" && !strings.EqualFold(header.Cty, "JWT") {
+		return "", fmt.Errorf("%w: jwe content is not a jwt", ErrDecryptionFailed)
+	}
+
+	cek, err := rsa.DecryptOAEP(sha1.New(), nil, key, decoded[1], nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrDecryptionFailed, err)
+	}
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrDecryptionFailed, err)
+	}
+	if len(decoded[2]) != gcm.NonceSize() {
+		return "", fmt.Errorf("%w: invalid jwe initialization vector", ErrDecryptionFailed)
+	}
+
+	signed, err := gcm.Open(nil, decoded[2], append(decoded[3], decoded[4]...), []byte(parts[0]))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrDecryptionFailed, err)
+	}
+	return string(signed), nil
+}
+
+func newGCM(cek []byte) (cipher.AEAD, error) {
+	if len(cek) != 32 {
+		return nil, fmt.Errorf("invalid content encryption key length %d", len(cek))
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
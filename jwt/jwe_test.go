@@ -0,0 +1,104 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_JWE_Encode_Decode(t *testing.T) {
+	encKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithDecryptionKey(encKey))
+	require.Nil(t, err)
+
+	payload := Payload{
+		Customer:      "abc123",
+		RealUser:      "xyz234",
+		EffectiveUser: "xyz345",
+		Claims:        Claims{"email": "someone@example.com"},
+	}
+	encrypted, err := encoder.Encode(payload, WithEncryption(&encKey.PublicKey))
+	require.Nil(t, err)
+	assert.Len(t, strings.Split(encrypted, "."), 5)
+	assert.NotContains(t, encrypted, base64.RawURLEncoding.EncodeToString([]byte(`"email"`)))
+
+	decoded, err := decoder.Decode(encrypted)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", decoded.Customer)
+	assert.Equal(t, "xyz234", decoded.RealUser)
+	assert.Equal(t, "xyz345", decoded.EffectiveUser)
+	assert.Equal(t, "someone@example.com", decoded.Claims["email"])
+
+	// signed tokens keep working alongside encrypted ones
+	signed, err := encoder.Encode(payload)
+	require.Nil(t, err)
+	decoded, err = decoder.Decode(signed)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", decoded.Customer)
+}
+
+func Test_JWE_DecryptionFails(t *testing.T) {
+	encKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem", WithEncryption(&encKey.PublicKey))
+	require.Nil(t, err)
+	encrypted, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	parts := strings.Split(encrypted, ".")
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[3])
+	require.Nil(t, err)
+	ciphertext[0] ^= 1
+	parts[3] = base64.RawURLEncoding.EncodeToString(ciphertext)
+	tampered := strings.Join(parts, ".")
+
+	cases := map[string]struct {
+		key      *rsa.PrivateKey
+		token    string
+		expected error
+	}{
+		"no key":    {nil, encrypted, ErrDecryptionFailed},
+		"wrong key": {otherKey, encrypted, ErrDecryptionFailed},
+		"tampered":  {encKey, tampered, ErrDecryptionFailed},
+		"malformed": {encKey, "a.b.c.d.!", ErrMalformed},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithDecryptionKey(c.key))
+			require.Nil(t, err)
+			_, err = decoder.Decode(c.token)
+			assert.True(t, errors.Is(err, c.expected), err)
+		})
+	}
+}
+
+func Test_JWE_InnerTokenIsVerified(t *testing.T) {
+	encKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	priPEM, _ := pemEncodeKeyPair(t, signingKey, &signingKey.PublicKey)
+
+	// encrypted for us, but signed with a key we don't trust
+	encoder, err := NewEncoderFromBytes(priPEM, WithEncryption(&encKey.PublicKey))
+	require.Nil(t, err)
+	encrypted, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithDecryptionKey(encKey))
+	require.Nil(t, err)
+	_, err = decoder.Decode(encrypted)
+	assert.True(t, errors.Is(err, ErrSignatureInvalid))
+}
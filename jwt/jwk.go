@@ -0,0 +1,269 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// jwk is the JSON representation of a single JSON Web Key (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA public key parameters
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP public key parameters
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+
+	// private key parameters
+	D  string `json:"d,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	DP string `json:"dp,omitempty"`
+	DQ string `json:"dq,omitempty"`
+	QI string `json:"qi,omitempty"`
+}
+
+// jwkSet is the JSON representation of a JSON Web Key Set (RFC 7517 section 5)
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// publicKey converts the JWK to the matching crypto public key
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		return k.rsaPublicKey()
+	case "EC":
+		return k.ecdsaPublicKey()
+	case "OKP":
+		return k.ed25519PublicKey()
+	default:
+		return nil, fmt.Errorf("unsupported jwk key type '%s'", k.Kty)
+	}
+}
+
+// privateKey converts the JWK to the matching crypto private key, checking
+// the private key matches the public key parameters
+func (k jwk) privateKey() (interface{}, error) {
+	if k.D == "" {
+		return nil, fmt.Errorf("jwk is not a private key")
+	}
+
+	switch k.Kty {
+	case "RSA":
+		return k.rsaPrivateKey()
+	case "EC":
+		return k.ecdsaPrivateKey()
+	case "OKP":
+		return k.ed25519PrivateKey()
+	default:
+		return nil, fmt.Errorf("unsupported jwk key type '%s'", k.Kty)
+	}
+}
+
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeJWKInt(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk modulus: %w", err)
+	}
+	e, err := decodeJWKInt(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk exponent: %w", err)
+	}
+	if !e.IsInt64() || e.Int64() > int64(^uint32(0)>>1) {
+		return nil, fmt.Errorf("invalid jwk exponent: out of range")
+	}
+
+	return &rsa.PublicKey{
+		N: n,
+		E: int(e.Int64()),
+	}, nil
+}
+
+func (k jwk) rsaPrivateKey() (*rsa.PrivateKey, error) {
+	pub, err := k.rsaPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := decodeJWKInt(k.D)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk private exponent: %w", err)
+	}
+	p, err := decodeJWKInt(k.P)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk first prime factor: %w", err)
+	}
+	q, err := decodeJWKInt(k.Q)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk second prime factor: %w", err)
+	}
+
+	key := &rsa.PrivateKey{
+		PublicKey: *pub,
+		D:         d,
+		Primes:    []*big.Int{p, q},
+	}
+	if err := key.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid jwk private key: %w", err)
+	}
+	key.Precompute()
+
+	return key, nil
+}
+
+func (k jwk) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported jwk curve '%s'", k.Crv)
+	}
+
+	x, err := decodeJWKInt(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk x coordinate: %w", err)
+	}
+	y, err := decodeJWKInt(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk y coordinate: %w", err)
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, fmt.Errorf("invalid jwk: point is not on curve %s", k.Crv)
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     x,
+		Y:     y,
+	}, nil
+}
+
+func (k jwk) ecdsaPrivateKey() (*ecdsa.PrivateKey, error) {
+	pub, err := k.ecdsaPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := decodeJWKInt(k.D)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk private key: %w", err)
+	}
+	x, y := pub.Curve.ScalarBaseMult(d.Bytes())
+	if x.Cmp(pub.X) != 0 || y.Cmp(pub.Y) != 0 {
+		return nil, fmt.Errorf("invalid jwk: private key does not match public key")
+	}
+
+	return &ecdsa.PrivateKey{
+		PublicKey: *pub,
+		D:         d,
+	}, nil
+}
+
+func (k jwk) ed25519PublicKey() (ed25519.PublicKey, error) {
+	if k.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported jwk curve '%s'", k.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk public key: %w", err)
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid jwk public key: wrong length %d", len(x))
+	}
+
+	return ed25519.PublicKey(x), nil
+}
+
+func (k jwk) ed25519PrivateKey() (ed25519.PrivateKey, error) {
+	pub, err := k.ed25519PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := base64.RawURLEncoding.DecodeString(k.D)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwk private key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid jwk private key: wrong length %d", len(seed))
+	}
+
+	key := ed25519.NewKeyFromSeed(seed)
+	if !pub.Equal(key.Public()) {
+		return nil, fmt.Errorf("invalid jwk: private key does not match public key")
+	}
+	return key, nil
+}
+
+// newPublicJWK converts the public part of 'key' to a JWK for signing with
+// "kid" 'kid' and algorithm 'alg'
+func newPublicJWK(key interface{}, kid string, alg string) (jwk, error) {
+	if signer, ok := key.(crypto.Signer); ok {
+		key = signer.Public()
+	}
+	if cert, ok := key.(*x509.Certificate); ok {
+		key = cert.PublicKey
+	}
+
+	k := jwk{
+		Use: "sig",
+		Kid: kid,
+		Alg: alg,
+	}
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		k.Kty = "RSA"
+		k.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		k.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// coordinates are padded to the size of the curve (RFC 7518 section 6.2.1.2)
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		k.Kty = "EC"
+		k.Crv = pub.Curve.Params().Name
+		k.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		k.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		k.Kty = "OKP"
+		k.Crv = "Ed25519"
+		k.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return jwk{}, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	return k, nil
+}
+
+// decodeJWKInt decodes a base64url encoded big-endian unsigned integer
+func decodeJWKInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("value is empty")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
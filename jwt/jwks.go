@@ -0,0 +1,239 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+const (
+	defaultJWKSRefreshInterval = 1 * time.Hour
+	defaultJWKSRefetchInterval = 1 * time.Minute
+	defaultJWKSTimeout         = 10 * time.Second
+
+	// maxJWKSSize caps the key set document read, far above any real set
+	maxJWKSSize = 1 << 20
+)
+
+// JWKS is a KeySet backed by a remote JSON Web Key Set document.
+//
+// The key set is fetched when it is created, cached, and refreshed in the
+// background. A token signed with a "kid" that is not in the cache causes one
+// immediate re-fetch, so keys rotated in by the identity service are accepted
+// straight away. These re-fetches are rate limited to protect the JWKS endpoint
+// from tokens carrying made up key ids.
+type JWKS struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	refetchInterval time.Duration
+	clock           Clock
+
+	mu   sync.RWMutex
+	keys map[string]interface{}
+
+	// fetchMu serialises fetches so concurrent requests with an unknown
+	// "kid" only hit the JWKS endpoint once. It also guards lastRefetch, which
+	// only re-fetches update so a scheduled refresh can't delay one.
+	fetchMu     sync.Mutex
+	lastRefetch time.Time
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// JWKSOption configures a JWKS
+type JWKSOption func(*JWKS)
+
+// WithJWKSHTTPClient sets the http.Client used to fetch the key set
+func WithJWKSHTTPClient(client *http.Client) JWKSOption {
+	return func(j *JWKS) {
+		j.client = client
+	}
+}
+
+// WithJWKSRefreshInterval sets how often the key set is refreshed in the
+// background. A zero or negative interval disables background refresh.
+func WithJWKSRefreshInterval(interval time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		j.refreshInterval = interval
+	}
+}
+
+// WithJWKSRefetchInterval sets the minimum time between re-fetches triggered
+// by tokens with an unknown "kid"
+func WithJWKSRefetchInterval(interval time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		j.refetchInterval = interval
+	}
+}
+
+// WithJWKSClock sets the Clock used to rate limit re-fetches, instead of the
+// system clock
+func WithJWKSClock(clock Clock) JWKSOption {
+	return func(j *JWKS) {
+		j.clock = clock
+	}
+}
+
+// NewJWKS fetches the JSON Web Key Set at 'url' and starts refreshing it in
+// the background. Call Close to stop the background refresh.
+func NewJWKS(url string, opts ...JWKSOption) (*JWKS, error) {
+	j := &JWKS{
+		url:             url,
+		client:          &http.Client{Timeout: defaultJWKSTimeout},
+		refreshInterval: defaultJWKSRefreshInterval,
+		refetchInterval: defaultJWKSRefetchInterval,
+		clock:           systemClock{},
+		keys:            map[string]interface{}{},
+		stop:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	if err := j.Refresh(); err != nil {
+		return nil, err
+	}
+
+	if j.refreshInterval > 0 {
+		go j.refreshLoop()
+	}
+
+	return j, nil
+}
+
+// Key returns the public key for 'kid', re-fetching the key set once if the
+// kid is unknown. A token without a "kid" is accepted only when the key set
+// holds a single key.
+func (j *JWKS) Key(kid string) (interface{}, error) {
+	if key, ok := j.lookup(kid); ok {
+		return key, nil
+	}
+
+	if j.refetch() {
+		if key, ok := j.lookup(kid); ok {
+			return key, nil
+		}
+	}
+
+	if kid == "" {
+		return nil, ErrMissingKeyID
+	}
+	return nil, ErrUnknownKeyID{Kid: kid}
+}
+
+// Refresh fetches the key set now, replacing the cached keys on success.
+// The cached keys are left untouched if the fetch fails.
+func (j *JWKS) Refresh() error {
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+
+	return j.fetch()
+}
+
+// Close stops the background refresh
+func (j *JWKS) Close() {
+	j.closeOnce.Do(func() {
+		close(j.stop)
+	})
+}
+
+func (j *JWKS) lookup(kid string) (interface{}, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// refetch re-fetches the key set unless a fetch was attempted within the
+// refetch interval, returning true if the cached keys may have changed
+func (j *JWKS) refetch() bool {
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+
+	now := j.clock.Now()
+	if now.Sub(j.lastRefetch) < j.refetchInterval {
+		// another caller may have fetched while we waited for the lock
+		return true
+	}
+
+	j.lastRefetch = now
+	return j.fetch() == nil
+}
+
+func (j *JWKS) refreshLoop() {
+	ticker := time.NewTicker(j.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// on failure keep serving the cached keys until the next tick
+			_ = j.Refresh()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// fetch downloads and parses the key set. Callers must hold fetchMu.
+func (j *JWKS) fetch() error {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxJWKSSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	if len(body) > maxJWKSSize {
+		return fmt.Errorf("failed to fetch jwks: larger than %d bytes", maxJWKSSize)
+	}
+
+	var set jwkSet
+	if err := json.Unmarshal(body, &set); err != nil {
+		return fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// skip keys we can't use rather than rejecting the whole set
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("no usable signing keys in jwks")
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jwksServer serves a JSON Web Key Set that tests can change on the fly
+type jwksServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	set   jwkSet
+	hits  int
+	fails bool
+}
+
+func newJWKSServer(keys ...jwk) *jwksServer {
+	s := &jwksServer{set: jwkSet{Keys: keys}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.hits++
+		if s.fails {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(s.set)
+	}))
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...jwk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set = jwkSet{Keys: keys}
+}
+
+func (s *jwksServer) hitCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits
+}
+
+func rsaJWK(t *testing.T, kid string, key *rsa.PublicKey) jwk {
+	t.Helper()
+	return jwk{
+		Kty: "RSA",
+		Use: "sig",
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func devKeys(t *testing.T) (*rsa.PrivateKey, *rsa.PublicKey) {
+	t.Helper()
+	priBytes, err := ioutil.ReadFile("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+	priKey, err := jwtgo.ParseRSAPrivateKeyFromPEM(priBytes)
+	require.Nil(t, err)
+	return priKey, &priKey.PublicKey
+}
+
+func signWithKid(t *testing.T, key *rsa.PrivateKey, kid string) string {
+	t.Helper()
+	token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, jwtgo.MapClaims{
+		"accountId":       "abc123",
+		"realUserId":      "xyz234",
+		"effectiveUserId": "xyz345",
+		"exp":             time.Now().Add(time.Minute).Unix(),
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.Nil(t, err)
+	return signed
+}
+
+func Test_JWKS_Decode(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	server := newJWKSServer(rsaJWK(t, "key-1", pubKey))
+	defer server.Close()
+
+	decoder, err := NewDecoderFromJWKS(server.URL)
+	assert.Nil(t, err)
+
+	payload, err := decoder.Decode(signWithKid(t, priKey, "key-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", payload.Customer)
+	assert.Equal(t, "xyz234", payload.RealUser)
+	assert.Equal(t, "xyz345", payload.EffectiveUser)
+}
+
+func Test_JWKS_NoKid_SingleKey(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	server := newJWKSServer(rsaJWK(t, "key-1", pubKey))
+	defer server.Close()
+
+	jwks, err := NewJWKS(server.URL, WithJWKSRefreshInterval(0))
+	assert.Nil(t, err)
+
+	_, err = NewDecoderFromKeySet(jwks).Decode(signWithKid(t, priKey, ""))
+	assert.Nil(t, err)
+}
+
+func Test_JWKS_UnknownKid_Refetches(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	server := newJWKSServer(rsaJWK(t, "key-1", pubKey))
+	defer server.Close()
+
+	jwks, err := NewJWKS(server.URL, WithJWKSRefreshInterval(0), WithJWKSRefetchInterval(0))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, server.hitCount())
+
+	// the identity service rotates in a new key
+	server.setKeys(rsaJWK(t, "key-1", pubKey), rsaJWK(t, "key-2", pubKey))
+
+	_, err = NewDecoderFromKeySet(jwks).Decode(signWithKid(t, priKey, "key-2"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, server.hitCount())
+}
+
+func Test_JWKS_UnknownKid_RateLimited(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	server := newJWKSServer(rsaJWK(t, "key-1", pubKey))
+	defer server.Close()
+
+	jwks, err := NewJWKS(server.URL, WithJWKSRefreshInterval(0), WithJWKSRefetchInterval(time.Hour))
+	assert.Nil(t, err)
+
+	decoder := NewDecoderFromKeySet(jwks)
+	for i := 0; i < 5; i++ {
+		_, err = decoder.Decode(signWithKid(t, priKey, "made-up"))
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, 2, server.hitCount())
+}
+
+func Test_JWKS_UnknownKid_AfterRefresh(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	server := newJWKSServer(rsaJWK(t, "key-1", pubKey))
+	defer server.Close()
+
+	jwks, err := NewJWKS(server.URL, WithJWKSRefreshInterval(0), WithJWKSRefetchInterval(time.Hour))
+	assert.Nil(t, err)
+
+	// a refresh just before a key rotation doesn't delay fetching the new key
+	assert.Nil(t, jwks.Refresh())
+	server.setKeys(rsaJWK(t, "key-1", pubKey), rsaJWK(t, "key-2", pubKey))
+
+	_, err = NewDecoderFromKeySet(jwks).Decode(signWithKid(t, priKey, "key-2"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, server.hitCount())
+}
+
+func Test_JWKS_UnknownKid_Clock(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	server := newJWKSServer(rsaJWK(t, "key-1", pubKey))
+	defer server.Close()
+
+	clock := newTestClock()
+	jwks, err := NewJWKS(server.URL, WithJWKSRefreshInterval(0), WithJWKSRefetchInterval(time.Minute), WithJWKSClock(clock))
+	assert.Nil(t, err)
+
+	decoder := NewDecoderFromKeySet(jwks)
+	_, err = decoder.Decode(signWithKid(t, priKey, "made-up"))
+	assert.NotNil(t, err)
+	_, err = decoder.Decode(signWithKid(t, priKey, "made-up"))
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, server.hitCount())
+
+	// re-fetching is allowed again once the interval has passed
+	clock.advance(time.Minute)
+	_, err = decoder.Decode(signWithKid(t, priKey, "made-up"))
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, server.hitCount())
+}
+
+func Test_JWKS_TooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[],"padding":"`))
+		_, _ = w.Write(make([]byte, maxJWKSSize))
+	}))
+	defer server.Close()
+
+	_, err := NewJWKS(server.URL)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "larger than")
+}
+
+func Test_JWKS_BackgroundRefresh(t *testing.T) {
+	_, pubKey := devKeys(t)
+	server := newJWKSServer(rsaJWK(t, "key-1", pubKey))
+	defer server.Close()
+
+	jwks, err := NewJWKS(server.URL, WithJWKSRefreshInterval(10*time.Millisecond), WithJWKSRefetchInterval(time.Hour))
+	assert.Nil(t, err)
+	defer jwks.Close()
+
+	server.setKeys(rsaJWK(t, "key-2", pubKey))
+
+	assert.Eventually(t, func() bool {
+		_, ok := jwks.lookup("key-2")
+		return ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_JWKS_FailedRefreshKeepsKeys(t *testing.T) {
+	_, pubKey := devKeys(t)
+	server := newJWKSServer(rsaJWK(t, "key-1", pubKey))
+	defer server.Close()
+
+	jwks, err := NewJWKS(server.URL, WithJWKSRefreshInterval(0))
+	assert.Nil(t, err)
+
+	server.mu.Lock()
+	server.fails = true
+	server.mu.Unlock()
+
+	assert.NotNil(t, jwks.Refresh())
+	key, err := jwks.Key("key-1")
+	assert.Nil(t, err)
+	assert.Equal(t, pubKey, key)
+}
+
+func Test_JWKS_FetchFails(t *testing.T) {
+	server := newJWKSServer()
+	defer server.Close()
+
+	_, err := NewJWKS(server.URL)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,155 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+// JWKSPath is the conventional path to publish a JWK set at
+const JWKSPath = "/.well-known/jwks.json"
+
+// defaultJWKSMaxAge is how long clients may cache a published JWK set. It is
+// kept short so retired keys disappear soon after they retire.
+const defaultJWKSMaxAge = 5 * time.Minute
+
+// retiringKey is a public key that stays in the Encoder's JWK set after it
+// was rotated out, until the tokens it signed have expired
+type retiringKey struct {
+	jwk      jwk
+	retireAt time.Time
+}
+
+// WithRetiringKey returns a copy of the Encoder that also publishes the public
+// key 'publicKey' (PEM, certificate or JWK) with "kid" 'kid' in its JWK set
+// until 'retireAt', so tokens signed with the key it replaced still verify.
+// 'retireAt' should be no earlier than when the key stopped signing plus the
+// longest token expiry.
+func (encoder Encoder) WithRetiringKey(kid string, publicKey []byte, retireAt time.Time) (Encoder, error) {
+	if kid == "" {
+		return Encoder{}, errors.New("retiring key must have a 'kid'")
+	}
+
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return Encoder{}, err
+	}
+	alg, err := algorithmForKey(key)
+	if err != nil {
+		return Encoder{}, err
+	}
+	k, err := newPublicJWK(key, kid, alg)
+	if err != nil {
+		return Encoder{}, err
+	}
+
+	// copy so the original Encoder is unchanged
+	retiring := make([]retiringKey, len(encoder.retiring), len(encoder.retiring)+1)
+	copy(retiring, encoder.retiring)
+	encoder.retiring = append(retiring, retiringKey{jwk: k, retireAt: retireAt})
+	return encoder, nil
+}
+
+// PublicKeySet returns the JSON Web Key Set (RFC 7517) holding the public key
+// the Encoder signs with, and any retiring keys that have not yet retired. The
+// current key has the "kid" set by WithKeyID, if any.
+func (encoder Encoder) PublicKeySet() ([]byte, error) {
+	signer := encoder.currentSigner()
+	if signer == nil {
+		return nil, errors.New("no signing key configured for jwt encoder")
+	}
+
+	config := encoder.config(nil)
+	current, err := newPublicJWK(signer.Public(), config.keyID, signer.Algorithm())
+	if err != nil {
+		return nil, err
+	}
+
+	set := jwkSet{Keys: []jwk{current}}
+	now := config.clock.Now()
+	for _, r := range encoder.retiring {
+		if now.Before(r.retireAt) && r.jwk.Kid != current.Kid {
+			set.Keys = append(set.Keys, r.jwk)
+		}
+	}
+
+	return json.Marshal(set)
+}
+
+// JWKSHandlerOption configures the handler created by NewJWKSHandler
+type JWKSHandlerOption func(*jwksHandler)
+
+// WithJWKSMaxAge sets how long clients may cache the JWK set, 5 minutes by
+// default
+func WithJWKSMaxAge(maxAge time.Duration) JWKSHandlerOption {
+	return func(h *jwksHandler) {
+		h.maxAge = maxAge
+	}
+}
+
+type jwksHandler struct {
+	encoder Encoder
+	maxAge  time.Duration
+}
+
+// NewJWKSHandler creates an http.Handler publishing the public keys of
+// 'encoder', see Encoder.PublicKeySet, so other services can verify the tokens
+// it signs with NewDecoderFromJWKS. Mount it at JWKSPath. The set is rebuilt
+// on each request, so a reloaded signing key is published straight away.
+func NewJWKSHandler(encoder Encoder, opts ...JWKSHandlerOption) http.Handler {
+	h := jwksHandler{
+		encoder: encoder,
+		maxAge:  defaultJWKSMaxAge,
+	}
+	for _, opt := range opts {
+		opt(&h)
+	}
+
+	return h
+}
+
+func (h jwksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := h.encoder.PublicKeySet()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.maxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+// etagMatches returns true if the If-None-Match header value 'header' matches
+// 'etag', ignoring weak validator prefixes
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+
+	return false
+}
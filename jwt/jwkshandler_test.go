@@ -0,0 +1,138 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_JWKSHandler_PublicJWK(t *testing.T) {
+	_, rsaKey := devKeys(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.Nil(t, err)
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	for _, key := range []interface{}{rsaKey, &ecKey.PublicKey, edKey} {
+		k, err := newPublicJWK(key, "kid", "alg")
+		require.Nil(t, err)
+
+		pub, err := k.publicKey()
+		require.Nil(t, err)
+		assert.Equal(t, key, pub)
+	}
+
+	// private keys publish their public part only
+	k, err := newPublicJWK(ecKey, "kid", "ES384")
+	require.Nil(t, err)
+	assert.Equal(t, "", k.D)
+	assert.Equal(t, "P-384", k.Crv)
+}
+
+func Test_JWKSHandler_Decode(t *testing.T) {
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem", WithKeyID("2021"))
+	require.Nil(t, err)
+
+	server := httptest.NewServer(NewJWKSHandler(encoder))
+	defer server.Close()
+
+	decoder, err := NewDecoderFromJWKS(server.URL + JWKSPath)
+	require.Nil(t, err)
+
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+	payload, err := decoder.Decode(token)
+	require.Nil(t, err)
+	assert.Equal(t, "abc123", payload.Customer)
+}
+
+func Test_JWKSHandler_Rotation(t *testing.T) {
+	oldEncoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem", WithKeyID("old"))
+	require.Nil(t, err)
+	oldPublic, err := ioutil.ReadFile("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	newPEM, _ := pemEncodeKeyPair(t, newKey, &newKey.PublicKey)
+	newEncoder, err := NewEncoderFromBytes(newPEM, WithKeyID("new"))
+	require.Nil(t, err)
+
+	_, err = newEncoder.WithRetiringKey("", oldPublic, time.Now().Add(time.Hour))
+	assert.NotNil(t, err)
+
+	rotating, err := newEncoder.WithRetiringKey("old", oldPublic, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"new", "old"}, publishedKids(t, rotating))
+	assert.Equal(t, []string{"new"}, publishedKids(t, newEncoder), "original encoder is unchanged")
+
+	server := httptest.NewServer(NewJWKSHandler(rotating))
+	defer server.Close()
+	decoder, err := NewDecoderFromJWKS(server.URL, WithAllowedAlgorithms("RS256", "ES256"))
+	require.Nil(t, err)
+
+	for _, encoder := range []Encoder{oldEncoder, rotating} {
+		token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+		require.Nil(t, err)
+		_, err = decoder.Decode(token)
+		assert.Nil(t, err)
+	}
+
+	retired, err := newEncoder.WithRetiringKey("old", oldPublic, time.Now().Add(-time.Second))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"new"}, publishedKids(t, retired))
+}
+
+func Test_JWKSHandler_CacheHeaders(t *testing.T) {
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+	handler := NewJWKSHandler(encoder, WithJWKSMaxAge(time.Hour))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", JWKSPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/jwk-set+json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=3600", rec.Header().Get("Cache-Control"))
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	req := httptest.NewRequest("GET", JWKSPath, nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.Bytes())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", JWKSPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD", rec.Header().Get("Allow"))
+}
+
+// publishedKids returns the "kid" of each key in the JWK set of 'encoder'
+func publishedKids(t *testing.T, encoder Encoder) []string {
+	t.Helper()
+	body, err := encoder.PublicKeySet()
+	require.Nil(t, err)
+
+	var set jwkSet
+	require.Nil(t, json.Unmarshal(body, &set))
+
+	kids := []string{}
+	for _, k := range set.Keys {
+		assert.Equal(t, "sig", k.Use)
+		assert.NotEmpty(t, k.Alg)
+		kids = append(kids, k.Kid)
+	}
+	return kids
+}
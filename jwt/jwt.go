@@ -2,9 +2,6 @@ package jwt
 
 import (
 	"net/http"
-	"strings"
-
-	"github.com/go-errors/errors"
 )
 
 // Payload represents the jwt payload
@@ -12,6 +9,21 @@ type Payload struct {
 	Customer      string // uuid
 	RealUser      string // uuid
 	EffectiveUser string // uid
+
+	// Principal is the kind of identity the token is for. It is empty or
+	// PrincipalUser for users, which have RealUser and EffectiveUser set.
+	// PrincipalService tokens have Service set instead, and Customer if the
+	// service is scoped to an account.
+	Principal PrincipalType
+	Service   string
+
+	// Scopes are the permissions granted to the token, from the "scope" and
+	// "permissions" claims. Use HasScope to check them.
+	Scopes []string
+
+	// Claims holds every claim in a decoded token, including those above.
+	// When encoding, these are added to the token as extra claims.
+	Claims Claims
 }
 
 // DecodeJwtToken interface defines how to decode a JWT token string
@@ -19,18 +31,14 @@ type DecodeJwtToken interface {
 	Decode(tokenString string) (Payload, error)
 }
 
-// PayloadFromRequest returns a Payload given a http.Request and a DecodeJwtToken
-func PayloadFromRequest(r *http.Request, jwtDecoder DecodeJwtToken) (Payload, error) {
-	auth := r.Header.Get("Authorization") // "Authorization: Bearer xxxxx.yyyyy.zzzzz"
-	if len(auth) == 0 {
-		return Payload{}, errors.New("missing authorization header")
-	}
-
-	splitToken := strings.Split(auth, "Bearer")
-	if len(splitToken) < 2 {
-		return Payload{}, errors.New("missing 'Bearer' token in authorization header")
+// PayloadFromRequest returns a Payload given a http.Request and a DecodeJwtToken.
+// The token is read from the Authorization header, or by 'extractors' in
+// order if any are given.
+func PayloadFromRequest(r *http.Request, jwtDecoder DecodeJwtToken, extractors ...TokenExtractor) (Payload, error) {
+	token, err := tokenExtractor(extractors).Extract(r)
+	if err != nil {
+		return Payload{}, err
 	}
 
-	token := strings.TrimSpace(splitToken[1])
 	return jwtDecoder.Decode(token)
 }
@@ -1,75 +1,305 @@
 package jwt
 
 import (
+	"crypto/rand"
 	"crypto/rsa"
-	jwtgo "github.com/dgrijalva/jwt-go"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"encoding/hex"
+	"fmt"
+	"strings"
 	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-errors/errors"
 )
 
+// Were a little loose on the expiry for now, to avoid possible
+// problems with clock skew, slow requests, background jobs (?) etc.
+const defaultExpiry = 10 * time.Minute
+
 // Encoder represents a jwt encoder
 type Encoder struct {
-	pemKey *rsa.PrivateKey
+	signer   Signer
+	defaults []EncodeOption
+	retiring []retiringKey
+}
+
+// EncodeOption sets a standard claim or header on an encoded token. Options
+// given to the Encoder constructors apply to every token, and can be
+// overridden by options given to Encode.
+type EncodeOption func(*encodeConfig)
+
+// encodeConfig holds the standard claims and headers set on an encoded token
+type encodeConfig struct {
+	expiry    time.Duration
+	issuer    string
+	audience  []string
+	subject   string
+	id        string
+	notBefore time.Time
+	keyID     string
+
+	encryptionKey *rsa.PublicKey
+	refreshExpiry time.Duration
+	refreshFamily string // set only when encoding a refresh token
+
+	impersonationStartedAt time.Time
+
+	accountKeys *AccountKeys
+
+	clock Clock
 }
 
-// Claims contains the claims to be used to sign JWT's returned by Identity API
-type claims struct {
-	AccountID       string `json:"accountId"`
-	EffectiveUserID string `json:"effectiveUserId"`
-	RealUserID      string `json:"realUserId"`
-	jwtgo.StandardClaims
+// WithExpiry sets how long the token is valid for ("exp" claim)
+func WithExpiry(expiry time.Duration) EncodeOption {
+	return func(c *encodeConfig) {
+		c.expiry = expiry
+	}
+}
+
+// WithIssuerClaim sets the "iss" claim
+func WithIssuerClaim(issuer string) EncodeOption {
+	return func(c *encodeConfig) {
+		c.issuer = issuer
+	}
 }
 
-// NewEncoder creates a new Encoder
-func NewEncoder() (Encoder, error) {
-	priKey := os.Getenv("AUTH_PRIVATE_KEY")
-	return NewEncoderFromBytes([]byte(priKey))
+// WithAudienceClaim sets the "aud" claim. A single audience is encoded as a
+// string, more than one as an array.
+func WithAudienceClaim(audience ...string) EncodeOption {
+	return func(c *encodeConfig) {
+		c.audience = audience
+	}
+}
+
+// WithSubjectClaim sets the "sub" claim
+func WithSubjectClaim(subject string) EncodeOption {
+	return func(c *encodeConfig) {
+		c.subject = subject
+	}
+}
+
+// WithIDClaim sets the "jti" claim. By default every token gets a random,
+// unique jti.
+func WithIDClaim(id string) EncodeOption {
+	return func(c *encodeConfig) {
+		c.id = id
+	}
 }
 
-// NewEncoderFromPath creates a new Encoder given the private key at 'pemKeyPath'
-func NewEncoderFromPath(pemKeyPath string) (Encoder, error) {
-	pemBytes, _ := ioutil.ReadFile(filepath.Clean(pemKeyPath))
-	return NewEncoderFromBytes(pemBytes)
+// WithNotBefore sets the "nbf" claim, the time before which the token must not
+// be accepted
+func WithNotBefore(notBefore time.Time) EncodeOption {
+	return func(c *encodeConfig) {
+		c.notBefore = notBefore
+	}
+}
+
+// WithKeyID sets the "kid" header, so the receiver can pick the key to verify
+// the token with
+func WithKeyID(kid string) EncodeOption {
+	return func(c *encodeConfig) {
+		c.keyID = kid
+	}
+}
+
+// WithEncoderClock sets the Clock tokens are issued at ("iat" claim) and their
+// expiry is calculated from, instead of the system clock
+func WithEncoderClock(clock Clock) EncodeOption {
+	return func(c *encodeConfig) {
+		c.clock = clock
+	}
+}
+
+// NewEncoder creates a new Encoder with the private key in the
+// AUTH_PRIVATE_KEY environment variable
+func NewEncoder(opts ...EncodeOption) (Encoder, error) {
+	return NewEncoderFromKeySource(NewEnvKeySource("AUTH_PRIVATE_KEY"), opts...)
+}
+
+// NewEncoderFromPath creates a new Encoder given the private key at 'pemKeyPath'.
+// The file is reloaded when it changes, see FileKeySource.
+func NewEncoderFromPath(pemKeyPath string, opts ...EncodeOption) (Encoder, error) {
+	return NewEncoderFromKeySource(NewFileKeySource(pemKeyPath), opts...)
+}
+
+// NewEncoderFromBytes creates a new Encoder given the RSA, ECDSA or Ed25519
+// private key as a []byte. The key may be PEM encoded (PKCS#1, SEC 1 or
+// PKCS#8) or a JWK.
+func NewEncoderFromBytes(pemBytes []byte, opts ...EncodeOption) (Encoder, error) {
+	return NewEncoderFromKeySource(NewBytesKeySource(pemBytes), opts...)
+}
+
+// NewEncoderFromJWK creates a new Encoder given the RSA, ECDSA or Ed25519
+// private key as a JSON Web Key (RFC 7517)
+func NewEncoderFromJWK(jwkBytes []byte, opts ...EncodeOption) (Encoder, error) {
+	return newEncoderFromKeySource(NewBytesKeySource(jwkBytes), parsePrivateKeyFromJWK, opts)
+}
+
+// NewEncoderFromKeySource creates a new Encoder with the RSA, ECDSA or Ed25519
+// private key loaded from 'source', in any of the formats accepted by
+// NewEncoderFromBytes. Tokens are signed with the algorithm matching the key:
+// RS256 for RSA, ES256/ES384/ES512 for ECDSA depending on the curve and EdDSA
+// for Ed25519.
+func NewEncoderFromKeySource(source KeySource, opts ...EncodeOption) (Encoder, error) {
+	return newEncoderFromKeySource(source, parsePrivateKey, opts)
+}
+
+func newEncoderFromKeySource(source KeySource, parse func([]byte) (interface{}, error), opts []EncodeOption) (Encoder, error) {
+	key, err := newSourceKey(source, parse)
+	if err != nil {
+		return Encoder{}, err
+	}
+
+	return NewEncoderFromSigner(key, opts...)
 }
 
-// NewEncoderFromBytes creates a new Encoder given the private key as a []byte
-func NewEncoderFromBytes(pemBytes []byte) (Encoder, error) {
-	pemKey, err := jwtgo.ParseRSAPrivateKeyFromPEM(pemBytes)
+// NewEncoderFromSigner creates a new Encoder that delegates signing to
+// 'signer', eg. a KMSSigner so the private key never leaves the key
+// management service
+func NewEncoderFromSigner(signer Signer, opts ...EncodeOption) (Encoder, error) {
+	if signer == nil {
+		return Encoder{}, errors.New("no signer given for jwt encoder")
+	}
+
 	return Encoder{
-		pemKey: pemKey,
-	}, err
+		signer:   signer,
+		defaults: opts,
+	}, nil
 }
 
 // Encode a Payload
-func (encoder Encoder) Encode(payload Payload) (string, error) {
-	// Were a little loose on the expiry for now, to avoid possible
-	// problems with clock skew, slow requests, background jobs (?) etc.
-	expiry := 10 * time.Minute
-	claims := encoder.claims(payload, expiry)
-	token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, claims)
-	return token.SignedString(encoder.pemKey)
+func (encoder Encoder) Encode(payload Payload, opts ...EncodeOption) (string, error) {
+	signer := encoder.currentSigner()
+	if signer == nil {
+		return "", errors.New("no signing key configured for jwt encoder")
+	}
+
+	config := encoder.config(opts)
+	accountSigner, kid, err := config.accountSigner(payload)
+	if err != nil {
+		return "", err
+	}
+	if accountSigner != nil {
+		signer = accountSigner
+		config.keyID = kid
+	}
+	method := jwtgo.GetSigningMethod(signer.Algorithm())
+	if method == nil {
+		return "", fmt.Errorf("unsupported signing algorithm '%s'", signer.Algorithm())
+	}
+
+	if config.id == "" {
+		id, err := newTokenID()
+		if err != nil {
+			return "", err
+		}
+		config.id = id
+	}
+
+	claims, err := encoder.claims(payload, config)
+	if err != nil {
+		return "", err
+	}
+	token := jwtgo.NewWithClaims(method, claims)
+	if config.refreshFamily != "" {
+		token.Header["typ"] = refreshTokenType
+	}
+	if config.keyID != "" {
+		token.Header["kid"] = config.keyID
+	}
+	signed, err := sign(token, signer)
+	if err != nil || config.encryptionKey == nil {
+		return signed, err
+	}
+
+	return encryptToken(signed, config.encryptionKey)
+}
+
+// config applies the Encoder defaults and then 'opts'
+func (encoder Encoder) config(opts []EncodeOption) encodeConfig {
+	config := encodeConfig{
+		expiry:        defaultExpiry,
+		refreshExpiry: defaultRefreshExpiry,
+		clock:         systemClock{},
+	}
+	for _, opt := range encoder.defaults {
+		opt(&config)
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return config
 }
 
 // EncodeWithExpiry encodes a Payload with an expiry
-func (encoder Encoder) EncodeWithExpiry(payload Payload, duration time.Duration) (string, error) {
-	claims := encoder.claims(payload, duration)
-	token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, claims)
-	return token.SignedString(encoder.pemKey)
-}
-
-func (encoder Encoder) claims(payload Payload, duration time.Duration) claims {
-	now := time.Now()
-	return claims{
-		AccountID:       payload.Customer,
-		EffectiveUserID: payload.EffectiveUser,
-		RealUserID:      payload.RealUser,
-		StandardClaims: jwtgo.StandardClaims{
-			IssuedAt: now.Unix(),
-			// Were a little loose on the expiry for now, to avoid possible
-			// problems with clock skew, slow requests, background jobs (?) etc.
-			ExpiresAt: now.Add(duration).Unix(),
-		},
+func (encoder Encoder) EncodeWithExpiry(payload Payload, duration time.Duration, opts ...EncodeOption) (string, error) {
+	return encoder.Encode(payload, append(opts, WithExpiry(duration))...)
+}
+
+// currentSigner returns the Signer for the current key. A reloadable key is
+// read once, so the key and algorithm of a token match if it is reloaded.
+func (encoder Encoder) currentSigner() Signer {
+	if s, ok := encoder.signer.(snapshotSigner); ok {
+		return s.snapshot()
+	}
+
+	return encoder.signer
+}
+
+// claims returns the claims to be used to sign JWT's returned by Identity API.
+// Any extra claims in the payload are included, but can't replace the standard,
+// identity or time based claims.
+func (encoder Encoder) claims(payload Payload, config encodeConfig) (jwtgo.MapClaims, error) {
+	now := config.clock.Now()
+
+	claims := jwtgo.MapClaims{}
+	for name, value := range payload.Claims {
+		claims[name] = value
+	}
+
+	if config.issuer != "" {
+		claims["iss"] = config.issuer
+	}
+	switch len(config.audience) {
+	case 0:
+	case 1:
+		claims["aud"] = config.audience[0]
+	default:
+		claims["aud"] = config.audience
+	}
+	if config.subject != "" {
+		claims["sub"] = config.subject
+	}
+	if !config.notBefore.IsZero() {
+		claims["nbf"] = config.notBefore.Unix()
 	}
+	claims["jti"] = config.id
+	if !config.impersonationStartedAt.IsZero() && payload.IsImpersonating() {
+		claims[ImpersonationStartedAtClaim] = config.impersonationStartedAt.Unix()
+	}
+
+	if err := principalClaims(payload, claims); err != nil {
+		return nil, err
+	}
+	if len(payload.Scopes) > 0 {
+		claims[ScopeClaim] = strings.Join(payload.Scopes, " ")
+	}
+	if config.refreshFamily != "" {
+		refreshClaims(claims, config.refreshFamily)
+	}
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(config.expiry).Unix()
+
+	return claims, nil
+}
+
+// newTokenID returns a random 128 bit token id for the "jti" claim
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	jwtgo "github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
 	"net/http/httptest"
 )
@@ -107,3 +108,64 @@ func Test_PayloadFromRequest_InvalidToken(t *testing.T) {
 	_, err = PayloadFromRequest(req, jwt)
 	assert.NotNil(t, err)
 }
+
+func Test_JWT_Encode_Options(t *testing.T) {
+	jwtEncoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem",
+		WithIssuerClaim("identity-api"),
+		WithKeyID("2021"),
+		WithExpiry(time.Hour),
+	)
+	assert.Nil(t, err)
+
+	notBefore := time.Now().Add(-time.Minute).Truncate(time.Second)
+	token, err := jwtEncoder.Encode(Payload{
+		Customer:      "abc123",
+		RealUser:      "xyz234",
+		EffectiveUser: "xyz345",
+	}, WithAudienceClaim("murmur", "perform"), WithSubjectClaim("xyz345"), WithNotBefore(notBefore), WithKeyID("2022"))
+	assert.Nil(t, err)
+
+	parsed, _, err := new(jwtgo.Parser).ParseUnverified(token, jwtgo.MapClaims{})
+	assert.Nil(t, err)
+	assert.Equal(t, "2022", parsed.Header["kid"])
+
+	jwtDecoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub",
+		WithIssuer("identity-api"),
+		WithAudience("perform"),
+	)
+	assert.Nil(t, err)
+
+	payload, err := jwtDecoder.Decode(token)
+	assert.Nil(t, err)
+	assert.Equal(t, "identity-api", payload.Claims["iss"])
+	assert.Equal(t, "xyz345", payload.Claims["sub"])
+	assert.Equal(t, float64(notBefore.Unix()), payload.Claims["nbf"])
+	assert.Len(t, payload.Claims["jti"], 32)
+
+	iat := payload.Claims["iat"].(float64)
+	exp := payload.Claims["exp"].(float64)
+	assert.Equal(t, time.Hour.Seconds(), exp-iat)
+}
+
+func Test_JWT_Encode_UniqueID(t *testing.T) {
+	jwtEncoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	assert.Nil(t, err)
+	jwtDecoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	assert.Nil(t, err)
+
+	ids := map[interface{}]bool{}
+	for i := 0; i < 10; i++ {
+		token, err := jwtEncoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+		assert.Nil(t, err)
+		payload, err := jwtDecoder.Decode(token)
+		assert.Nil(t, err)
+		ids[payload.Claims["jti"]] = true
+	}
+	assert.Len(t, ids, 10)
+
+	token, err := jwtEncoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"}, WithIDClaim("fixed"))
+	assert.Nil(t, err)
+	payload, err := jwtDecoder.Decode(token)
+	assert.Nil(t, err)
+	assert.Equal(t, "fixed", payload.Claims["jti"])
+}
@@ -0,0 +1,43 @@
+package jwttest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a jwt.Clock for tests that only moves when told to. Pass it to
+// jwt.WithDecoderClock, jwt.WithEncoderClock and jwt.WithStoreClock to run
+// expiry scenarios without sleeping.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a Clock stopped at 'now'
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the time the Clock is stopped at
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Set stops the Clock at 'now'
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+}
+
+// Advance moves the Clock forward by 'd'
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,114 @@
+package jwttest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cultureamp/gocampers/jwt"
+	"github.com/cultureamp/gocampers/jwt/jwttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KeyPairs_EncodeDecode(t *testing.T) {
+	cases := map[string]jwttest.KeyPair{
+		"RS256": jwttest.NewRSAKeyPair(t),
+		"ES256": jwttest.NewECDSAKeyPair(t),
+		"EdDSA": jwttest.NewEd25519KeyPair(t),
+	}
+
+	for alg, keys := range cases {
+		t.Run(alg, func(t *testing.T) {
+			assert.Equal(t, alg, keys.Algorithm)
+			token, err := keys.Encoder(t).Encode(jwt.Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+			assert.Nil(t, err)
+
+			payload, err := keys.Decoder(t).Decode(token)
+			assert.Nil(t, err)
+			assert.Equal(t, "abc123", payload.Customer)
+
+			payload, err = keys.Decoder(t).Decode(keys.Token().Sign(t))
+			assert.Nil(t, err)
+			assert.Equal(t, jwttest.AccountID, payload.Customer)
+			assert.Equal(t, jwttest.RealUserID, payload.RealUser)
+		})
+	}
+}
+
+func Test_NewEncoderDecoder(t *testing.T) {
+	encoder, decoder := jwttest.NewEncoderDecoder(t)
+	token, err := encoder.Encode(jwt.Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	assert.Nil(t, err)
+	_, err = decoder.Decode(token)
+	assert.Nil(t, err)
+}
+
+func Test_TokenBuilder_Failures(t *testing.T) {
+	keys := jwttest.NewRSAKeyPair(t)
+	other := jwttest.NewRSAKeyPair(t)
+	decoder := keys.Decoder(t)
+
+	cases := map[string]struct {
+		token    string
+		expected error
+	}{
+		"expired":         {keys.Token().Expired().Sign(t), jwt.ErrTokenExpired},
+		"not valid yet":   {keys.Token().NotValidYet().Sign(t), jwt.ErrTokenNotValidYet},
+		"wrong signature": {keys.Token().WrongSignature().Sign(t), jwt.ErrSignatureInvalid},
+		"other key":       {keys.Token().SignedWith(other).Sign(t), jwt.ErrSignatureInvalid},
+		"missing claim":   {keys.Token().WithoutClaim("realUserId").Sign(t), jwt.ErrMissingClaim{Name: "realUserId"}},
+		"alg none":        {keys.Token().Algorithm("none").Sign(t), jwt.ErrAlgorithmNotAllowed},
+		"alg confusion":   {keys.Token().Algorithm("HS256").Sign(t), jwt.ErrAlgorithmNotAllowed},
+		"other alg":       {keys.Token().Algorithm("RS512").Sign(t), jwt.ErrAlgorithmNotAllowed},
+		"service":         {keys.Token().Service("worker").Sign(t), jwt.ErrInvalidClaim{Name: "principalType"}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := decoder.Decode(c.token)
+			assert.True(t, errors.Is(err, c.expected), err)
+		})
+	}
+}
+
+func Test_TokenBuilder_Claims(t *testing.T) {
+	keys := jwttest.NewECDSAKeyPair(t)
+	decoder := keys.Decoder(t, jwt.WithIssuer("https://identity.example.com"), jwt.WithPrincipalTypes(jwt.PrincipalUser, jwt.PrincipalService))
+
+	payload, err := decoder.Decode(keys.Token().
+		User("abc123", "xyz234", "xyz345").
+		Claim("iss", "https://identity.example.com").
+		Claim("email", "someone@example.com").
+		KeyID("2021").
+		Sign(t))
+	assert.Nil(t, err)
+	assert.True(t, payload.IsImpersonating())
+	assert.Equal(t, "someone@example.com", payload.Claims["email"])
+
+	payload, err = decoder.Decode(keys.Token().Service("worker").Claim("iss", "https://identity.example.com").Sign(t))
+	assert.Nil(t, err)
+	assert.Equal(t, "worker", payload.Service)
+	assert.Equal(t, jwttest.AccountID, payload.Customer)
+}
+
+func Test_Clock_Expiry(t *testing.T) {
+	keys := jwttest.NewRSAKeyPair(t)
+	clock := jwttest.NewClock(time.Date(2021, 11, 8, 9, 0, 0, 0, time.UTC))
+	decoder := keys.Decoder(t, jwt.WithDecoderClock(clock))
+
+	token := keys.Token().IssuedAt(clock.Now()).Sign(t)
+	_, err := decoder.Decode(token)
+	assert.Nil(t, err)
+
+	clock.Advance(10 * time.Minute)
+	_, err = decoder.Decode(token)
+	assert.Nil(t, err)
+
+	clock.Advance(time.Second)
+	_, err = decoder.Decode(token)
+	assert.True(t, errors.Is(err, jwt.ErrTokenExpired))
+
+	clock.Set(time.Date(2021, 11, 8, 8, 0, 0, 0, time.UTC))
+	_, err = decoder.Decode(token)
+	assert.True(t, errors.Is(err, jwt.ErrTokenUsedBeforeIssued))
+}
@@ -0,0 +1,117 @@
+// Package jwttest generates in-memory keys and tokens for testing code that
+// uses the jwt package, without needing key files or hard-coded tokens.
+//
+//	keys := jwttest.NewRSAKeyPair(t)
+//	decoder := keys.Decoder(t)
+//
+//	valid := keys.Token().Sign(t)
+//	expired := keys.Token().Expired().Sign(t)
+//	tampered := keys.Token().WrongSignature().Sign(t)
+package jwttest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/cultureamp/gocampers/jwt"
+)
+
+// KeyPair is a private key and its public key, generated for a test
+type KeyPair struct {
+	Private    crypto.Signer
+	Public     crypto.PublicKey
+	PrivatePEM []byte
+	PublicPEM  []byte
+	// Algorithm is the signing algorithm used with the key, eg. "RS256"
+	Algorithm string
+}
+
+// NewRSAKeyPair generates a 2048 bit RSA key pair, used with RS256
+func NewRSAKeyPair(t testing.TB) KeyPair {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+
+	return newKeyPair(t, key, "RS256")
+}
+
+// NewECDSAKeyPair generates a P-256 ECDSA key pair, used with ES256
+func NewECDSAKeyPair(t testing.TB) KeyPair {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %s", err)
+	}
+
+	return newKeyPair(t, key, "ES256")
+}
+
+// NewEd25519KeyPair generates an Ed25519 key pair, used with EdDSA
+func NewEd25519KeyPair(t testing.TB) KeyPair {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %s", err)
+	}
+
+	return newKeyPair(t, key, "EdDSA")
+}
+
+func newKeyPair(t testing.TB, key crypto.Signer, alg string) KeyPair {
+	t.Helper()
+	priDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode private key: %s", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("failed to encode public key: %s", err)
+	}
+
+	return KeyPair{
+		Private:    key,
+		Public:     key.Public(),
+		PrivatePEM: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priDER}),
+		PublicPEM:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}),
+		Algorithm:  alg,
+	}
+}
+
+// Encoder returns a jwt.Encoder signing with the private key
+func (k KeyPair) Encoder(t testing.TB, opts ...jwt.EncodeOption) jwt.Encoder {
+	t.Helper()
+	encoder, err := jwt.NewEncoderFromBytes(k.PrivatePEM, opts...)
+	if err != nil {
+		t.Fatalf("failed to create encoder: %s", err)
+	}
+
+	return encoder
+}
+
+// Decoder returns a jwt.Decoder verifying with the public key
+func (k KeyPair) Decoder(t testing.TB, opts ...jwt.DecoderOption) jwt.Decoder {
+	t.Helper()
+	decoder, err := jwt.NewDecoderFromBytes(k.PublicPEM, opts...)
+	if err != nil {
+		t.Fatalf("failed to create decoder: %s", err)
+	}
+
+	return decoder
+}
+
+// NewEncoderDecoder generates an RSA key pair and returns a matching Encoder
+// and Decoder
+func NewEncoderDecoder(t testing.TB) (jwt.Encoder, jwt.Decoder) {
+	t.Helper()
+	keys := NewRSAKeyPair(t)
+	return keys.Encoder(t), keys.Decoder(t)
+}
@@ -0,0 +1,177 @@
+package jwttest
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+)
+
+// The identity claims of tokens built by a TokenBuilder, unless changed
+const (
+	AccountID       = "test-account"
+	RealUserID      = "test-user"
+	EffectiveUserID = "test-user"
+)
+
+// TokenBuilder builds a token for a test. By default it is a valid user token
+// for AccountID and RealUserID, expiring in 10 minutes and signed with the
+// KeyPair.
+type TokenBuilder struct {
+	keys           KeyPair
+	claims         jwtgo.MapClaims
+	header         map[string]interface{}
+	alg            string
+	wrongSignature bool
+	now            time.Time
+}
+
+// Token starts building a token signed with the private key
+func (k KeyPair) Token() *TokenBuilder {
+	now := time.Now()
+	return &TokenBuilder{
+		keys: k,
+		claims: jwtgo.MapClaims{
+			"accountId":       AccountID,
+			"realUserId":      RealUserID,
+			"effectiveUserId": EffectiveUserID,
+			"iat":             now.Unix(),
+			"exp":             now.Add(10 * time.Minute).Unix(),
+		},
+		header: map[string]interface{}{},
+		alg:    k.Algorithm,
+		now:    now,
+	}
+}
+
+// Claim sets the claim 'name' to 'value'
+func (b *TokenBuilder) Claim(name string, value interface{}) *TokenBuilder {
+	b.claims[name] = value
+	return b
+}
+
+// WithoutClaim removes the claim 'name', eg. to test a missing "realUserId"
+func (b *TokenBuilder) WithoutClaim(name string) *TokenBuilder {
+	delete(b.claims, name)
+	return b
+}
+
+// User sets the account, real user and effective user claims
+func (b *TokenBuilder) User(account string, realUser string, effectiveUser string) *TokenBuilder {
+	return b.Claim("accountId", account).Claim("realUserId", realUser).Claim("effectiveUserId", effectiveUser)
+}
+
+// Service makes the token a service token for 'service', scoped to the
+// account claim if it is set
+func (b *TokenBuilder) Service(service string) *TokenBuilder {
+	return b.WithoutClaim("realUserId").WithoutClaim("effectiveUserId").
+		Claim("principalType", "service").Claim("serviceName", service)
+}
+
+// Scopes sets the "scope" claim to 'scopes', space separated
+func (b *TokenBuilder) Scopes(scopes ...string) *TokenBuilder {
+	return b.Claim("scope", strings.Join(scopes, " "))
+}
+
+// IssuedAt issues the token at 'now' rather than the current time, expiring
+// 10 minutes later. Later calls to ExpiresIn, Expired and NotValidYet are
+// relative to 'now', eg. to match a Decoder using a Clock.
+func (b *TokenBuilder) IssuedAt(now time.Time) *TokenBuilder {
+	b.now = now
+	return b.Claim("iat", now.Unix()).ExpiresIn(10 * time.Minute)
+}
+
+// ExpiresIn sets the "exp" claim to 'd' from now
+func (b *TokenBuilder) ExpiresIn(d time.Duration) *TokenBuilder {
+	return b.Claim("exp", b.now.Add(d).Unix())
+}
+
+// Expired makes the token expire a minute ago
+func (b *TokenBuilder) Expired() *TokenBuilder {
+	return b.Claim("iat", b.now.Add(-time.Hour).Unix()).ExpiresIn(-time.Minute)
+}
+
+// NotValidYet sets the "nbf" claim to a minute from now
+func (b *TokenBuilder) NotValidYet() *TokenBuilder {
+	return b.Claim("nbf", b.now.Add(time.Minute).Unix())
+}
+
+// Header sets the header 'name' to 'value'
+func (b *TokenBuilder) Header(name string, value interface{}) *TokenBuilder {
+	b.header[name] = value
+	return b
+}
+
+// KeyID sets the "kid" header
+func (b *TokenBuilder) KeyID(kid string) *TokenBuilder {
+	return b.Header("kid", kid)
+}
+
+// Algorithm signs the token with 'alg' rather than the algorithm of the key.
+// "none" produces an unsigned token, and "HS256", "HS384" or "HS512" use the
+// PEM encoded public key as the HMAC secret, as in an algorithm confusion
+// attack. Any other algorithm must be usable with the private key, eg.
+// "RS512" or "PS256" with an RSA key.
+func (b *TokenBuilder) Algorithm(alg string) *TokenBuilder {
+	b.alg = alg
+	return b
+}
+
+// WrongSignature corrupts the signature, so it fails verification
+func (b *TokenBuilder) WrongSignature() *TokenBuilder {
+	b.wrongSignature = true
+	return b
+}
+
+// SignedWith signs the token with another key pair, eg. one the Decoder under
+// test does not trust
+func (b *TokenBuilder) SignedWith(keys KeyPair) *TokenBuilder {
+	b.keys = keys
+	b.alg = keys.Algorithm
+	return b
+}
+
+// Sign returns the signed token
+func (b *TokenBuilder) Sign(t testing.TB) string {
+	t.Helper()
+	method := jwtgo.GetSigningMethod(b.alg)
+	if method == nil {
+		t.Fatalf("unknown signing algorithm %s", b.alg)
+	}
+
+	token := jwtgo.NewWithClaims(method, b.claims)
+	for name, value := range b.header {
+		token.Header[name] = value
+	}
+
+	var key interface{} = b.keys.Private
+	switch {
+	case method == jwtgo.SigningMethodNone:
+		key = jwtgo.UnsafeAllowNoneSignatureType
+	case strings.HasPrefix(b.alg, "HS"):
+		key = b.keys.PublicPEM
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %s", err)
+	}
+	if b.wrongSignature {
+		signed = corruptSignature(signed)
+	}
+	return signed
+}
+
+// corruptSignature flips a bit in the signature of 'token'
+func corruptSignature(token string) string {
+	i := strings.LastIndex(token, ".")
+	sig, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || len(sig) == 0 {
+		return token + "AAAA"
+	}
+
+	sig[0] ^= 1
+	return token[:i+1] + base64.RawURLEncoding.EncodeToString(sig)
+}
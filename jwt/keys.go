@@ -0,0 +1,179 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+)
+
+// parsePublicKey parses an RSA, ECDSA or Ed25519 public key, detecting whether
+// it is a JWK, a PEM encoded key or certificate, or a DER encoded certificate
+func parsePublicKey(keyBytes []byte) (interface{}, error) {
+	if isJSON(keyBytes) {
+		return parsePublicKeyFromJWK(keyBytes)
+	}
+	if block, _ := pem.Decode(keyBytes); block == nil {
+		if cert, err := x509.ParseCertificate(keyBytes); err == nil {
+			return cert, nil
+		}
+	}
+
+	return parsePublicKeyFromPEM(keyBytes)
+}
+
+// parsePrivateKey parses an RSA, ECDSA or Ed25519 private key, detecting
+// whether it is a JWK or PEM encoded
+func parsePrivateKey(keyBytes []byte) (interface{}, error) {
+	if isJSON(keyBytes) {
+		return parsePrivateKeyFromJWK(keyBytes)
+	}
+
+	return parsePrivateKeyFromPEM(keyBytes)
+}
+
+// parsePublicKeyFromJWK parses a single JSON Web Key (RFC 7517) holding an
+// RSA, ECDSA or Ed25519 public key. The public part of a private key is used.
+func parsePublicKeyFromJWK(jwkBytes []byte) (interface{}, error) {
+	var k jwk
+	if err := json.Unmarshal(jwkBytes, &k); err != nil {
+		return nil, fmt.Errorf("failed to parse jwk: %w", err)
+	}
+
+	return k.publicKey()
+}
+
+// parsePrivateKeyFromJWK parses a single JSON Web Key (RFC 7517) holding an
+// RSA, ECDSA or Ed25519 private key
+func parsePrivateKeyFromJWK(jwkBytes []byte) (interface{}, error) {
+	var k jwk
+	if err := json.Unmarshal(jwkBytes, &k); err != nil {
+		return nil, fmt.Errorf("failed to parse jwk: %w", err)
+	}
+
+	return k.privateKey()
+}
+
+// parseCertificate parses a PEM or DER encoded X.509 certificate for an RSA,
+// ECDSA or Ed25519 public key
+func parseCertificate(certBytes []byte) (interface{}, error) {
+	der := certBytes
+	if block, _ := pem.Decode(certBytes); block != nil {
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("expected a CERTIFICATE, not %s", block.Type)
+		}
+		der = block.Bytes
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := algorithmForKey(cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// isJSON returns true if 'b' looks like a JSON object
+func isJSON(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(b), []byte("{"))
+}
+
+// parsePublicKeyFromPEM parses a PEM encoded RSA, ECDSA or Ed25519 public key
+// in PKIX or PKCS#1 form, or a PEM encoded X.509 certificate. Certificates are
+// returned as an *x509.Certificate, so the Decoder can check they are still
+// valid whenever they are used.
+func parsePublicKeyFromPEM(pemBytes []byte) (interface{}, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, jwtgo.ErrKeyMustBePEMEncoded
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		key, err = x509.ParseCertificate(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := algorithmForKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// parsePrivateKeyFromPEM parses a PEM encoded RSA, ECDSA or Ed25519 private
+// key in PKCS#1, SEC 1 or PKCS#8 form
+func parsePrivateKeyFromPEM(pemBytes []byte) (interface{}, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, jwtgo.ErrKeyMustBePEMEncoded
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := algorithmForKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// algorithmForKey returns the JWS "alg" that is used with a public or private
+// key, or the public key of a certificate: RS256 for RSA, ES256/ES384/ES512
+// for ECDSA depending on the curve and EdDSA for Ed25519
+func algorithmForKey(key interface{}) (string, error) {
+	switch k := key.(type) {
+	case *x509.Certificate:
+		return algorithmForKey(k.PublicKey)
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return jwtgo.SigningMethodRS256.Alg(), nil
+	case *ecdsa.PublicKey:
+		return algorithmForCurve(k.Curve)
+	case *ecdsa.PrivateKey:
+		return algorithmForCurve(k.Curve)
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return SigningMethodEdDSA.Alg(), nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+func algorithmForCurve(curve elliptic.Curve) (string, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwtgo.SigningMethodES256.Alg(), nil
+	case elliptic.P384():
+		return jwtgo.SigningMethodES384.Alg(), nil
+	case elliptic.P521():
+		return jwtgo.SigningMethodES512.Alg(), nil
+	default:
+		return "", fmt.Errorf("unsupported elliptic curve %s", curve.Params().Name)
+	}
+}
@@ -0,0 +1,315 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pemEncodeKeyPair(t *testing.T, priKey interface{}, pubKey interface{}) ([]byte, []byte) {
+	t.Helper()
+	priDER, err := x509.MarshalPKCS8PrivateKey(priKey)
+	require.Nil(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(pubKey)
+	require.Nil(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+}
+
+func encodeDecode(t *testing.T, priPEM []byte, pubPEM []byte) (string, Payload, error) {
+	t.Helper()
+	encoder, err := NewEncoderFromBytes(priPEM)
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromBytes(pubPEM)
+	require.Nil(t, err)
+
+	token, err := encoder.Encode(Payload{
+		Customer:      "abc123",
+		RealUser:      "xyz234",
+		EffectiveUser: "xyz345",
+	})
+	require.Nil(t, err)
+
+	payload, err := decoder.Decode(token)
+	return token, payload, err
+}
+
+func tokenAlg(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := new(jwtgo.Parser).ParseUnverified(token, jwtgo.MapClaims{})
+	require.Nil(t, err)
+	return parsed.Method.Alg()
+}
+
+func Test_Keys_ECDSA_Encode_Decode(t *testing.T) {
+	cases := map[string]elliptic.Curve{
+		"ES256": elliptic.P256(),
+		"ES384": elliptic.P384(),
+	}
+
+	for alg, curve := range cases {
+		t.Run(alg, func(t *testing.T) {
+			priKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+			require.Nil(t, err)
+			priPEM, pubPEM := pemEncodeKeyPair(t, priKey, &priKey.PublicKey)
+
+			token, payload, err := encodeDecode(t, priPEM, pubPEM)
+			assert.Nil(t, err)
+			assert.Equal(t, alg, tokenAlg(t, token))
+			assert.Equal(t, "abc123", payload.Customer)
+		})
+	}
+}
+
+func Test_Keys_SEC1_ECDSA_PrivateKey(t *testing.T) {
+	priKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	der, err := x509.MarshalECPrivateKey(priKey)
+	require.Nil(t, err)
+
+	encoder, err := NewEncoderFromBytes(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	require.Nil(t, err)
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+	assert.Equal(t, "ES256", tokenAlg(t, token))
+
+	_, pubPEM := pemEncodeKeyPair(t, priKey, &priKey.PublicKey)
+	decoder, err := NewDecoderFromBytes(pubPEM)
+	require.Nil(t, err)
+	payload, err := decoder.Decode(token)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", payload.Customer)
+}
+
+func Test_Keys_Ed25519_Encode_Decode(t *testing.T) {
+	pubKey, priKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	priPEM, pubPEM := pemEncodeKeyPair(t, priKey, pubKey)
+
+	token, payload, err := encodeDecode(t, priPEM, pubPEM)
+	assert.Nil(t, err)
+	assert.Equal(t, "EdDSA", tokenAlg(t, token))
+	assert.Equal(t, "abc123", payload.Customer)
+	assert.Equal(t, "xyz234", payload.RealUser)
+	assert.Equal(t, "xyz345", payload.EffectiveUser)
+}
+
+func Test_Keys_Ed25519_WrongKey(t *testing.T) {
+	pubKey, priKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	priPEM, _ := pemEncodeKeyPair(t, priKey, pubKey)
+	_, otherPubPEM := pemEncodeKeyPair(t, priKey, otherPub)
+
+	_, _, err = encodeDecode(t, priPEM, otherPubPEM)
+	assert.NotNil(t, err)
+}
+
+func Test_Decoder_RejectsAlgorithmConfusion(t *testing.T) {
+	// an attacker signs a HS256 token using the (public) RSA key as the HMAC secret
+	pubBytes, err := ioutil.ReadFile("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+	token := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, jwtgo.MapClaims{
+		"accountId":       "abc123",
+		"realUserId":      "xyz234",
+		"effectiveUserId": "xyz345",
+	})
+	signed, err := token.SignedString(pubBytes)
+	require.Nil(t, err)
+
+	decoder, err := NewDecoderFromBytes(pubBytes)
+	require.Nil(t, err)
+
+	_, err = decoder.Decode(signed)
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrAlgorithmNotAllowed))
+}
+
+func Test_Decoder_AllowedAlgorithms(t *testing.T) {
+	priKey, _ := devKeys(t)
+	pubBytes, err := ioutil.ReadFile("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+	token := signWithKid(t, priKey, "")
+
+	decoder, err := NewDecoderFromBytes(pubBytes, WithAllowedAlgorithms("ES256", "EdDSA"))
+	require.Nil(t, err)
+	_, err = decoder.Decode(token)
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrAlgorithmNotAllowed))
+
+	decoder, err = NewDecoderFromBytes(pubBytes, WithAllowedAlgorithms("ES256", "RS256"))
+	require.Nil(t, err)
+	_, err = decoder.Decode(token)
+	assert.Nil(t, err)
+}
+
+func Test_Decoder_KeySet_MixedKeyTypes(t *testing.T) {
+	rsaPriKey, rsaPubKey := devKeys(t)
+	ecPriKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	decoder := NewDecoderFromKeySet(NewStaticKeySet(map[string]interface{}{
+		"rsa": rsaPubKey,
+		"ec":  &ecPriKey.PublicKey,
+	}, nil), WithAllowedAlgorithms("RS256", "ES256"))
+
+	_, err = decoder.Decode(signWithKid(t, rsaPriKey, "rsa"))
+	assert.Nil(t, err)
+
+	ecToken := jwtgo.NewWithClaims(jwtgo.SigningMethodES256, jwtgo.MapClaims{
+		"accountId":       "abc123",
+		"realUserId":      "xyz234",
+		"effectiveUserId": "xyz345",
+	})
+	ecToken.Header["kid"] = "ec"
+	signed, err := ecToken.SignedString(ecPriKey)
+	require.Nil(t, err)
+	_, err = decoder.Decode(signed)
+	assert.Nil(t, err)
+
+	// the RSA kid can't be used to verify an ES256 token
+	ecToken.Header["kid"] = "rsa"
+	signed, err = ecToken.SignedString(ecPriKey)
+	require.Nil(t, err)
+	_, err = decoder.Decode(signed)
+	assert.NotNil(t, err)
+}
+
+func Test_JWK_ECDSA_And_Ed25519(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	key, err := jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+	}.publicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, &ecKey.PublicKey, key)
+
+	key, err = jwk{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(edPub),
+	}.publicKey()
+	assert.Nil(t, err)
+	assert.Equal(t, edPub, key)
+
+	_, err = jwk{Kty: "EC", Crv: "P-256", X: "AQ", Y: "AQ"}.publicKey()
+	assert.NotNil(t, err)
+}
+
+func Test_Keys_UnsupportedPEM(t *testing.T) {
+	_, err := NewDecoderFromBytes([]byte("not a pem"))
+	assert.NotNil(t, err)
+
+	_, err = NewEncoderFromBytes([]byte(strings.Repeat("-", 10)))
+	assert.NotNil(t, err)
+}
+
+func Test_Keys_JWK_Encode_Decode(t *testing.T) {
+	rsaKey, _ := devKeys(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	rsaPub := rsaJWK(t, "", &rsaKey.PublicKey)
+	rsaPri := rsaPub
+	rsaPri.D = b64(rsaKey.D.Bytes())
+	rsaPri.P = b64(rsaKey.Primes[0].Bytes())
+	rsaPri.Q = b64(rsaKey.Primes[1].Bytes())
+	ecPub := jwk{Kty: "EC", Crv: "P-256", X: b64(ecKey.X.Bytes()), Y: b64(ecKey.Y.Bytes())}
+	ecPri := ecPub
+	ecPri.D = b64(ecKey.D.Bytes())
+	edPubJWK := jwk{Kty: "OKP", Crv: "Ed25519", X: b64(edPub)}
+	edPri := edPubJWK
+	edPri.D = b64(edKey.Seed())
+
+	cases := map[string]struct {
+		pri jwk
+		pub jwk
+		alg string
+	}{
+		"RSA":     {rsaPri, rsaPub, "RS256"},
+		"ECDSA":   {ecPri, ecPub, "ES256"},
+		"Ed25519": {edPri, edPubJWK, "EdDSA"},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			priJSON, err := json.Marshal(c.pri)
+			require.Nil(t, err)
+			pubJSON, err := json.Marshal(c.pub)
+			require.Nil(t, err)
+
+			// the format is detected by the FromBytes constructors
+			token, payload, err := encodeDecode(t, priJSON, pubJSON)
+			assert.Nil(t, err)
+			assert.Equal(t, c.alg, tokenAlg(t, token))
+			assert.Equal(t, "abc123", payload.Customer)
+
+			encoder, err := NewEncoderFromJWK(priJSON)
+			require.Nil(t, err)
+			decoder, err := NewDecoderFromJWK(pubJSON)
+			require.Nil(t, err)
+			token, err = encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+			require.Nil(t, err)
+			_, err = decoder.Decode(token)
+			assert.Nil(t, err)
+
+			// a public key can't be used to sign
+			_, err = NewEncoderFromJWK(pubJSON)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func Test_Keys_JWK_MismatchedPrivateKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	priJSON, err := json.Marshal(jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   b64(ecKey.X.Bytes()),
+		Y:   b64(ecKey.Y.Bytes()),
+		D:   b64(otherKey.D.Bytes()),
+	})
+	require.Nil(t, err)
+
+	_, err = NewEncoderFromBytes(priJSON)
+	assert.NotNil(t, err)
+}
+
+func Test_Keys_PKCS8_RSA(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	priPEM, pubPEM := pemEncodeKeyPair(t, priKey, pubKey)
+
+	token, payload, err := encodeDecode(t, priPEM, pubPEM)
+	assert.Nil(t, err)
+	assert.Equal(t, "RS256", tokenAlg(t, token))
+	assert.Equal(t, "abc123", payload.Customer)
+}
@@ -0,0 +1,136 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-errors/errors"
+)
+
+// KeySet supplies the keys used to verify the signature of a JWT
+type KeySet interface {
+	// Key returns the verification key for the "kid" in the token header.
+	// kid is empty if the token header does not have one.
+	Key(kid string) (interface{}, error)
+}
+
+// StaticKeySet is a KeySet holding a fixed set of keys indexed by "kid". It
+// lets tokens signed with the old and new keys both be accepted during a key
+// rotation, without needing a remote JWKS.
+type StaticKeySet struct {
+	keys     map[string]interface{}
+	fallback interface{}
+}
+
+// NewStaticKeySet creates a StaticKeySet from a map of kid to public key.
+// 'fallback' is used for tokens without a "kid" header and may be nil, in
+// which case those tokens are rejected.
+func NewStaticKeySet(keys map[string]interface{}, fallback interface{}) *StaticKeySet {
+	copied := make(map[string]interface{}, len(keys))
+	for kid, key := range keys {
+		copied[kid] = key
+	}
+
+	return &StaticKeySet{
+		keys:     copied,
+		fallback: fallback,
+	}
+}
+
+// NewStaticKeySetFromDir creates a StaticKeySet from the PEM encoded RSA,
+// ECDSA or Ed25519 public keys in 'dir'. Each file ending in ".pem" or ".pub"
+// is loaded, using the file name without its extension as the kid. If
+// 'fallbackKid' is not empty the key with that kid is also used for tokens
+// without a "kid" header.
+func NewStaticKeySetFromDir(dir string, fallbackKid string) (*StaticKeySet, error) {
+	files, err := ioutil.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".pem" && ext != ".pub") {
+			continue
+		}
+
+		pemBytes, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		key, err := parsePublicKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %w", f.Name(), err)
+		}
+		keys[strings.TrimSuffix(f.Name(), ext)] = key
+	}
+
+	return newStaticKeySetWithFallbackKid(keys, fallbackKid)
+}
+
+// NewStaticKeySetFromJSON creates a StaticKeySet from a JSON Web Key Set
+// document (RFC 7517) stored in the file at 'path'. If 'fallbackKid' is not
+// empty the key with that kid is also used for tokens without a "kid" header.
+func NewStaticKeySetFromJSON(path string, fallbackKid string) (*StaticKeySet, error) {
+	jsonBytes, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var set jwkSet
+	if err := json.Unmarshal(jsonBytes, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Kid == "" {
+			return nil, errors.New("missing 'kid' for key in jwks")
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %s: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+
+	return newStaticKeySetWithFallbackKid(keys, fallbackKid)
+}
+
+func newStaticKeySetWithFallbackKid(keys map[string]interface{}, fallbackKid string) (*StaticKeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys found for key set")
+	}
+
+	var fallback interface{}
+	if fallbackKid != "" {
+		key, ok := keys[fallbackKid]
+		if !ok {
+			return nil, fmt.Errorf("fallback kid %s not found in key set", fallbackKid)
+		}
+		fallback = key
+	}
+
+	return NewStaticKeySet(keys, fallback), nil
+}
+
+// Key returns the public key for 'kid', or the fallback key if kid is empty
+func (s *StaticKeySet) Key(kid string) (interface{}, error) {
+	if kid == "" {
+		if s.fallback == nil {
+			return nil, ErrMissingKeyID
+		}
+		return s.fallback, nil
+	}
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID{Kid: kid}
+	}
+
+	return key, nil
+}
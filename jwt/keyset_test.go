@@ -0,0 +1,97 @@
+package jwt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StaticKeySet_Decode(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	decoder := NewDecoderFromKeySet(NewStaticKeySet(map[string]interface{}{
+		"2021": pubKey,
+		"2022": pubKey,
+	}, nil))
+
+	payload, err := decoder.Decode(signWithKid(t, priKey, "2021"))
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", payload.Customer)
+
+	_, err = decoder.Decode(signWithKid(t, priKey, "2022"))
+	assert.Nil(t, err)
+}
+
+func Test_StaticKeySet_UnknownKid(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	decoder := NewDecoderFromKeySet(NewStaticKeySet(map[string]interface{}{
+		"2021": pubKey,
+	}, pubKey))
+
+	_, err := decoder.Decode(signWithKid(t, priKey, "2019"))
+	assert.NotNil(t, err)
+	assert.Equal(t, "unknown 'kid' 2019 in jwt header", err.Error())
+}
+
+func Test_StaticKeySet_NoKid(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	token := signWithKid(t, priKey, "")
+
+	withFallback := NewDecoderFromKeySet(NewStaticKeySet(map[string]interface{}{"2021": pubKey}, pubKey))
+	_, err := withFallback.Decode(token)
+	assert.Nil(t, err)
+
+	withoutFallback := NewDecoderFromKeySet(NewStaticKeySet(map[string]interface{}{"2021": pubKey}, nil))
+	_, err = withoutFallback.Decode(token)
+	assert.NotNil(t, err)
+	assert.Equal(t, "missing 'kid' in jwt header", err.Error())
+}
+
+func Test_StaticKeySet_FromDir(t *testing.T) {
+	priKey, _ := devKeys(t)
+	pubBytes, err := ioutil.ReadFile("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	dir := t.TempDir()
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "2021.pem"), pubBytes, 0600))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "2022.pub"), pubBytes, 0600))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0600))
+
+	keys, err := NewStaticKeySetFromDir(dir, "2022")
+	assert.Nil(t, err)
+
+	decoder := NewDecoderFromKeySet(keys)
+	for _, kid := range []string{"2021", "2022", ""} {
+		_, err = decoder.Decode(signWithKid(t, priKey, kid))
+		assert.Nil(t, err, kid)
+	}
+
+	_, err = NewStaticKeySetFromDir(dir, "2019")
+	assert.NotNil(t, err)
+}
+
+func Test_StaticKeySet_FromDir_InvalidKey(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a key"), 0600))
+
+	_, err := NewStaticKeySetFromDir(dir, "")
+	assert.NotNil(t, err)
+}
+
+func Test_StaticKeySet_FromJSON(t *testing.T) {
+	priKey, pubKey := devKeys(t)
+	jsonBytes, err := json.Marshal(jwkSet{Keys: []jwk{rsaJWK(t, "2021", pubKey)}})
+	require.Nil(t, err)
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.Nil(t, ioutil.WriteFile(path, jsonBytes, 0600))
+
+	keys, err := NewStaticKeySetFromJSON(path, "")
+	assert.Nil(t, err)
+
+	_, err = NewDecoderFromKeySet(keys).Decode(signWithKid(t, priKey, "2021"))
+	assert.Nil(t, err)
+}
@@ -0,0 +1,242 @@
+package jwt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultKeyReloadInterval is how often a FileKeySource checks its file for changes
+const defaultKeyReloadInterval = 30 * time.Second
+
+// KeySource supplies the PEM encoded key used by an Encoder or Decoder
+type KeySource interface {
+	// Load returns the PEM encoded key, or an error if it can't be loaded
+	Load() ([]byte, error)
+}
+
+// ReloadableKeySource is a KeySource whose key can change while the process is
+// running. Encoders and Decoders created from one load the new key whenever
+// Changed returns true, and keep using the old key if the new one is invalid.
+type ReloadableKeySource interface {
+	KeySource
+	// Changed returns true if the key has changed since it was last loaded
+	Changed() bool
+}
+
+// EnvKeySource is a KeySource reading the key from an environment variable
+type EnvKeySource struct {
+	name string
+}
+
+// NewEnvKeySource creates a KeySource reading the key from the environment
+// variable 'name'
+func NewEnvKeySource(name string) EnvKeySource {
+	return EnvKeySource{name: name}
+}
+
+// Load returns the value of the environment variable
+func (s EnvKeySource) Load() ([]byte, error) {
+	value, ok := os.LookupEnv(s.name)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("%w: environment variable %s is not set", ErrKeyNotFound, s.name)
+	}
+
+	return []byte(value), nil
+}
+
+// BytesKeySource is a KeySource holding a fixed key
+type BytesKeySource struct {
+	pemBytes []byte
+}
+
+// NewBytesKeySource creates a KeySource for the PEM encoded key 'pemBytes'
+func NewBytesKeySource(pemBytes []byte) BytesKeySource {
+	return BytesKeySource{pemBytes: pemBytes}
+}
+
+// Load returns the key
+func (s BytesKeySource) Load() ([]byte, error) {
+	if len(s.pemBytes) == 0 {
+		return nil, fmt.Errorf("%w: key is empty", ErrKeyNotFound)
+	}
+
+	return s.pemBytes, nil
+}
+
+// KeySourceFunc is a KeySource calling a function to load the key, eg. to
+// fetch it from a secrets manager
+type KeySourceFunc func() ([]byte, error)
+
+// Load calls f
+func (f KeySourceFunc) Load() ([]byte, error) {
+	return f()
+}
+
+// FileKeySource is a ReloadableKeySource reading the key from a file. The
+// file is checked for changes periodically, so a key mounted from a Kubernetes
+// or ECS secret can be rotated without restarting the process.
+type FileKeySource struct {
+	path     string
+	interval time.Duration
+	clock    Clock
+
+	mu          sync.Mutex
+	lastChecked time.Time
+	modTime     time.Time
+	size        int64
+}
+
+// FileKeySourceOption configures a FileKeySource
+type FileKeySourceOption func(*FileKeySource)
+
+// WithKeyReloadInterval sets how often the file is checked for changes. An
+// interval of 0 disables reloading.
+func WithKeyReloadInterval(interval time.Duration) FileKeySourceOption {
+	return func(s *FileKeySource) {
+		s.interval = interval
+	}
+}
+
+// WithKeySourceClock sets the Clock used to decide when the file is next
+// checked, instead of the system clock
+func WithKeySourceClock(clock Clock) FileKeySourceOption {
+	return func(s *FileKeySource) {
+		s.clock = clock
+	}
+}
+
+// NewFileKeySource creates a FileKeySource reading the key from the file at
+// 'path', which is checked for changes every 30 seconds by default
+func NewFileKeySource(path string, opts ...FileKeySourceOption) *FileKeySource {
+	s := &FileKeySource{
+		path:     filepath.Clean(path),
+		interval: defaultKeyReloadInterval,
+		clock:    systemClock{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Load reads the key from the file
+func (s *FileKeySource) Load() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pemBytes, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if len(pemBytes) == 0 {
+		return nil, fmt.Errorf("%w: %s is empty", ErrKeyNotFound, s.path)
+	}
+
+	s.lastChecked = s.clock.Now()
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	return pemBytes, nil
+}
+
+// Changed returns true if the file has been modified since it was last loaded.
+// The file is only checked once per reload interval.
+func (s *FileKeySource) Changed() bool {
+	if s.interval <= 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	if now.Sub(s.lastChecked) < s.interval {
+		return false
+	}
+	s.lastChecked = now
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		// keep the current key if the file is missing while it is being replaced
+		return false
+	}
+	return !info.ModTime().Equal(s.modTime) || info.Size() != s.size
+}
+
+// loadedKey is a key parsed from a KeySource, with the algorithm it signs with
+type loadedKey struct {
+	key interface{}
+	alg string
+}
+
+// sourceKey holds the key parsed from a KeySource, swapping it atomically
+// when a ReloadableKeySource changes
+type sourceKey struct {
+	source KeySource
+	parse  func([]byte) (interface{}, error)
+	loaded atomic.Value // loadedKey
+}
+
+// newSourceKey loads and parses the key from 'source' with 'parse'
+func newSourceKey(source KeySource, parse func([]byte) (interface{}, error)) (*sourceKey, error) {
+	k := &sourceKey{
+		source: source,
+		parse:  parse,
+	}
+
+	loaded, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+	k.loaded.Store(loaded)
+	return k, nil
+}
+
+func (k *sourceKey) load() (loadedKey, error) {
+	pemBytes, err := k.source.Load()
+	if err != nil {
+		return loadedKey{}, err
+	}
+
+	key, err := k.parse(pemBytes)
+	if err != nil {
+		return loadedKey{}, err
+	}
+	alg, err := algorithmForKey(key)
+	if err != nil {
+		return loadedKey{}, err
+	}
+
+	return loadedKey{key: key, alg: alg}, nil
+}
+
+// get returns the current key, reloading it first if the source has changed
+func (k *sourceKey) get() loadedKey {
+	if r, ok := k.source.(ReloadableKeySource); ok && r.Changed() {
+		// an invalid key, eg. a partially written file, is ignored so the
+		// current key keeps working
+		if loaded, err := k.load(); err == nil {
+			k.loaded.Store(loaded)
+		}
+	}
+
+	return k.loaded.Load().(loadedKey)
+}
+
+// Key implements KeySet, returning the current key regardless of "kid"
+func (k *sourceKey) Key(kid string) (interface{}, error) {
+	return k.get().key, nil
+}
@@ -0,0 +1,157 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_KeySource_MissingFile(t *testing.T) {
+	_, err := NewDecoderFromPath("does-not-exist.pub")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	_, err = NewEncoderFromPath("does-not-exist.pem")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+}
+
+func Test_KeySource_Env(t *testing.T) {
+	pubBytes, err := ioutil.ReadFile("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	t.Setenv("TEST_JWT_PUBLIC_KEY", "")
+	_, err = NewDecoderFromKeySource(NewEnvKeySource("TEST_JWT_PUBLIC_KEY"))
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	t.Setenv("TEST_JWT_PUBLIC_KEY", string(pubBytes))
+	_, err = NewDecoderFromKeySource(NewEnvKeySource("TEST_JWT_PUBLIC_KEY"))
+	assert.Nil(t, err)
+}
+
+func Test_KeySource_Func(t *testing.T) {
+	priBytes, err := ioutil.ReadFile("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+
+	encoder, err := NewEncoderFromKeySource(KeySourceFunc(func() ([]byte, error) {
+		return priBytes, nil
+	}))
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+	_, err = decoder.Decode(token)
+	assert.Nil(t, err)
+
+	failed := errors.New("secrets manager unavailable")
+	_, err = NewEncoderFromKeySource(KeySourceFunc(func() ([]byte, error) {
+		return nil, failed
+	}))
+	assert.True(t, errors.Is(err, failed))
+}
+
+func Test_KeySource_FileReload(t *testing.T) {
+	oldPri, oldPub := devKeys(t)
+	newPri, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key.pub")
+	modTime := time.Now()
+	replace := func(pemBytes []byte) {
+		// replace the file the way a mounted secret is updated
+		tmp := filepath.Join(dir, "key.pub.tmp")
+		require.Nil(t, ioutil.WriteFile(tmp, pemBytes, 0600))
+		modTime = modTime.Add(time.Second)
+		require.Nil(t, os.Chtimes(tmp, modTime, modTime))
+		require.Nil(t, os.Rename(tmp, path))
+	}
+	_, oldPubPEM := pemEncodeKeyPair(t, oldPri, oldPub)
+	_, newPubPEM := pemEncodeKeyPair(t, newPri, &newPri.PublicKey)
+	replace(oldPubPEM)
+
+	decoder, err := NewDecoderFromKeySource(NewFileKeySource(path, WithKeyReloadInterval(time.Nanosecond)))
+	require.Nil(t, err)
+	_, err = decoder.Decode(signWithKid(t, oldPri, ""))
+	assert.Nil(t, err)
+
+	replace(newPubPEM)
+	_, err = decoder.Decode(signWithKid(t, newPri, ""))
+	assert.Nil(t, err)
+	_, err = decoder.Decode(signWithKid(t, oldPri, ""))
+	assert.True(t, errors.Is(err, ErrSignatureInvalid))
+
+	// an invalid key is ignored and the current key kept
+	replace([]byte("not a key"))
+	_, err = decoder.Decode(signWithKid(t, newPri, ""))
+	assert.Nil(t, err)
+}
+
+func Test_KeySource_FileReloadAlgorithm(t *testing.T) {
+	rsaPri, rsaPub := devKeys(t)
+	ecPri, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	ecSigner, err := NewKeySigner(ecPri)
+	require.Nil(t, err)
+	ecEncoder, err := NewEncoderFromSigner(ecSigner)
+	require.Nil(t, err)
+	ecToken, err := ecEncoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	path := filepath.Join(t.TempDir(), "key.pub")
+	_, rsaPubPEM := pemEncodeKeyPair(t, rsaPri, rsaPub)
+	require.Nil(t, ioutil.WriteFile(path, rsaPubPEM, 0600))
+
+	decoder, err := NewDecoderFromKeySource(NewFileKeySource(path, WithKeyReloadInterval(time.Nanosecond)))
+	require.Nil(t, err)
+	_, err = decoder.Decode(ecToken)
+	assert.True(t, errors.Is(err, ErrAlgorithmNotAllowed))
+
+	// the algorithm follows the key when it is replaced by one of another type
+	_, ecPubPEM := pemEncodeKeyPair(t, ecPri, ecPri.Public())
+	require.Nil(t, ioutil.WriteFile(path, ecPubPEM, 0600))
+	modTime := time.Now().Add(time.Second)
+	require.Nil(t, os.Chtimes(path, modTime, modTime))
+
+	_, err = decoder.Decode(ecToken)
+	assert.Nil(t, err)
+	_, err = decoder.Decode(signWithKid(t, rsaPri, ""))
+	assert.True(t, errors.Is(err, ErrAlgorithmNotAllowed))
+}
+
+func Test_KeySource_FileReloadClock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.pub")
+	require.Nil(t, ioutil.WriteFile(path, []byte("old"), 0600))
+	clock := newTestClock()
+	source := NewFileKeySource(path, WithKeyReloadInterval(time.Minute), WithKeySourceClock(clock))
+	_, err := source.Load()
+	require.Nil(t, err)
+
+	require.Nil(t, ioutil.WriteFile(path, []byte("changed"), 0600))
+	assert.False(t, source.Changed())
+
+	// the file is checked again once the interval has passed
+	clock.advance(time.Minute)
+	assert.True(t, source.Changed())
+}
+
+func Test_KeySource_FileReloadDisabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.pub")
+	require.Nil(t, ioutil.WriteFile(path, []byte("old"), 0600))
+	source := NewFileKeySource(path, WithKeyReloadInterval(0))
+	_, err := source.Load()
+	require.Nil(t, err)
+
+	require.Nil(t, ioutil.WriteFile(path, []byte("changed"), 0600))
+	assert.False(t, source.Changed())
+}
@@ -0,0 +1,193 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	_ "crypto/sha256" // digests signed by the service
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+const defaultKMSTimeout = 10 * time.Second
+
+// kmsAlgorithm is the KMS signing algorithm for a JWS algorithm, and the
+// digest it signs
+type kmsAlgorithm struct {
+	name string
+	hash crypto.Hash
+}
+
+// kmsSigningAlgorithms maps JWS algorithms onto KMS signing algorithms
+var kmsSigningAlgorithms = map[string]kmsAlgorithm{
+	"RS256": {name: "RSASSA_PKCS1_V1_5_SHA_256", hash: crypto.SHA256},
+	"ES256": {name: "ECDSA_SHA_256", hash: crypto.SHA256},
+	"ES384": {name: "ECDSA_SHA_384", hash: crypto.SHA384},
+	"ES512": {name: "ECDSA_SHA_512", hash: crypto.SHA512},
+}
+
+// KMSSigner is a Signer using an asymmetric key held in a key management
+// service with an AWS KMS compatible JSON API, so the private key never
+// enters the process. Only the digest of each token is sent to the service.
+//
+// Requests are sent unsigned; use WithKMSHTTPClient with a client whose
+// transport adds the credentials the service requires, eg. AWS SigV4.
+type KMSSigner struct {
+	endpoint string
+	keyID    string
+	client   *http.Client
+
+	public crypto.PublicKey
+	alg    string
+}
+
+// KMSSignerOption configures a KMSSigner
+type KMSSignerOption func(*KMSSigner)
+
+// WithKMSHTTPClient sets the http.Client used to call the service
+func WithKMSHTTPClient(client *http.Client) KMSSignerOption {
+	return func(s *KMSSigner) {
+		s.client = client
+	}
+}
+
+// NewKMSSigner creates a Signer using the key 'keyID' (a key id, ARN or alias)
+// in the service at 'endpoint', eg. "https://kms.ap-southeast-2.amazonaws.com".
+// The public key is fetched once to choose the algorithm: RS256 for RSA keys
+// and ES256/ES384/ES512 for ECDSA keys depending on the curve.
+func NewKMSSigner(endpoint string, keyID string, opts ...KMSSignerOption) (*KMSSigner, error) {
+	s := &KMSSigner{
+		endpoint: endpoint,
+		keyID:    keyID,
+		client:   &http.Client{Timeout: defaultKMSTimeout},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	var resp struct {
+		PublicKey []byte
+	}
+	err := s.call("GetPublicKey", map[string]interface{}{"KeyId": s.keyID}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kms public key: %w", err)
+	}
+	alg, err := algorithmForKey(public)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := kmsSigningAlgorithms[alg]; !ok {
+		return nil, fmt.Errorf("kms does not support signing algorithm '%s'", alg)
+	}
+
+	s.public = public
+	s.alg = alg
+	return s, nil
+}
+
+// Algorithm implements Signer
+func (s *KMSSigner) Algorithm() string {
+	return s.alg
+}
+
+// Public implements Signer
+func (s *KMSSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign implements Signer, asking the service to sign the digest of
+// 'signingInput'
+func (s *KMSSigner) Sign(signingInput []byte) ([]byte, error) {
+	alg := kmsSigningAlgorithms[s.alg]
+	h := alg.hash.New()
+	_, _ = h.Write(signingInput)
+
+	var resp struct {
+		Signature []byte
+	}
+	err := s.call("Sign", map[string]interface{}{
+		"KeyId":            s.keyID,
+		"Message":          h.Sum(nil),
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": alg.name,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if pub, ok := s.public.(*ecdsa.PublicKey); ok {
+		return ecdsaJWSSignature(resp.Signature, pub)
+	}
+	return resp.Signature, nil
+}
+
+// call invokes the service action 'action' with the JSON request 'req',
+// decoding the response into 'resp'. []byte fields are sent and received
+// base64 encoded, as the service expects.
+func (s *KMSSigner) call(action string, req interface{}, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	httpReq.Header.Set("X-Amz-Target", "TrentService."+action)
+
+	httpResp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("kms %s failed: %w", action, err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		var kmsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(httpResp.Body).Decode(&kmsErr)
+		return fmt.Errorf("kms %s failed: status %d: %s %s", action, httpResp.StatusCode, kmsErr.Type, kmsErr.Message)
+	}
+
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		return fmt.Errorf("kms %s failed: invalid response: %w", action, err)
+	}
+	return nil
+}
+
+// ecdsaJWSSignature converts the ASN.1 DER ECDSA signature returned by the
+// service to the fixed size R || S form JWS uses (RFC 7518 section 3.4)
+func ecdsaJWSSignature(der []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("invalid kms ecdsa signature: %w", err)
+	}
+
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	if sig.R.BitLen() > size*8 || sig.S.BitLen() > size*8 {
+		return nil, errors.New("invalid kms ecdsa signature: value too large")
+	}
+
+	out := make([]byte, 2*size)
+	sig.R.FillBytes(out[:size])
+	sig.S.FillBytes(out[size:])
+	return out, nil
+}
@@ -0,0 +1,157 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newKMSServer starts a stand-in for a KMS compatible service holding 'key'
+// as "test-key"
+func newKMSServer(t *testing.T, key crypto.Signer) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-amz-json-1.1", r.Header.Get("Content-Type"))
+
+		var req struct {
+			KeyId            string
+			Message          []byte
+			MessageType      string
+			SigningAlgorithm string
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.KeyId != "test-key" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"NotFoundException","message":"key not found"}`))
+			return
+		}
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			der, err := x509.MarshalPKIXPublicKey(key.Public())
+			require.Nil(t, err)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"KeyId": req.KeyId, "PublicKey": der})
+		case "TrentService.Sign":
+			assert.Equal(t, "DIGEST", req.MessageType)
+			hash := crypto.SHA256
+			if strings.HasSuffix(req.SigningAlgorithm, "_384") {
+				hash = crypto.SHA384
+			}
+			signature, err := key.Sign(rand.Reader, req.Message, hash)
+			require.Nil(t, err)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"KeyId": req.KeyId, "Signature": signature, "SigningAlgorithm": req.SigningAlgorithm})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func Test_KMSSigner_EncodeDecode(t *testing.T) {
+	rsaKey, _ := devKeys(t)
+	ec256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	ec384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.Nil(t, err)
+
+	cases := map[string]crypto.Signer{
+		"RS256": rsaKey,
+		"ES256": ec256,
+		"ES384": ec384,
+	}
+
+	for alg, key := range cases {
+		t.Run(alg, func(t *testing.T) {
+			server := newKMSServer(t, key)
+			defer server.Close()
+
+			signer, err := NewKMSSigner(server.URL, "test-key")
+			require.Nil(t, err)
+			assert.Equal(t, alg, signer.Algorithm())
+			assert.Equal(t, key.Public(), signer.Public())
+
+			encoder, err := NewEncoderFromSigner(signer, WithKeyID("kms-key"))
+			require.Nil(t, err)
+			token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+			require.Nil(t, err)
+
+			_, pubPEM := pemEncodeKeyPair(t, key, key.Public())
+			decoder, err := NewDecoderFromBytes(pubPEM, WithAllowedAlgorithms(alg))
+			require.Nil(t, err)
+			payload, err := decoder.Decode(token)
+			require.Nil(t, err)
+			assert.Equal(t, "xyz345", payload.EffectiveUser)
+		})
+	}
+}
+
+func Test_KMSSigner_Errors(t *testing.T) {
+	rsaKey, _ := devKeys(t)
+	server := newKMSServer(t, rsaKey)
+	defer server.Close()
+
+	_, err := NewKMSSigner(server.URL, "unknown-key")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "NotFoundException")
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	edServer := newKMSServer(t, edKey)
+	defer edServer.Close()
+	_, err = NewKMSSigner(edServer.URL, "test-key")
+	assert.NotNil(t, err)
+}
+
+func Test_Signer_KeySigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	signer, err := NewKeySigner(key)
+	require.Nil(t, err)
+	assert.Equal(t, "RS256", signer.Algorithm())
+
+	encoder, err := NewEncoderFromSigner(signer)
+	require.Nil(t, err)
+	token, err := encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	_, pubPEM := pemEncodeKeyPair(t, key, &key.PublicKey)
+	decoder, err := NewDecoderFromBytes(pubPEM)
+	require.Nil(t, err)
+	_, err = decoder.Decode(token)
+	assert.Nil(t, err)
+
+	_, err = NewEncoderFromSigner(nil)
+	assert.NotNil(t, err)
+}
+
+func Test_Signer_Failure(t *testing.T) {
+	signErr := errors.New("hsm unavailable")
+	encoder, err := NewEncoderFromSigner(failingSigner{err: signErr})
+	require.Nil(t, err)
+
+	_, err = encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	assert.True(t, errors.Is(err, signErr))
+}
+
+type failingSigner struct {
+	err error
+}
+
+func (s failingSigner) Algorithm() string        { return "RS256" }
+func (s failingSigner) Public() crypto.PublicKey { return nil }
+func (s failingSigner) Sign(signingInput []byte) ([]byte, error) {
+	return nil, s.err
+}
@@ -0,0 +1,139 @@
+package jwt
+
+import (
+	"fmt"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-errors/errors"
+)
+
+// PrincipalType is the kind of identity a token was issued to
+type PrincipalType string
+
+const (
+	// PrincipalUser is a person, identified by the "realUserId" and
+	// "effectiveUserId" claims. Tokens without a "principalType" claim are
+	// user tokens.
+	PrincipalUser PrincipalType = "user"
+	// PrincipalService is a machine identity such as a worker or cron job,
+	// identified by the "serviceName" claim and optionally scoped to an
+	// account by the "accountId" claim
+	PrincipalService PrincipalType = "service"
+)
+
+const (
+	principalTypeClaim = "principalType"
+	serviceNameClaim   = "serviceName"
+)
+
+// IsUser returns true if the Payload is for a person
+func (p Payload) IsUser() bool {
+	return p.Principal == "" || p.Principal == PrincipalUser
+}
+
+// IsService returns true if the Payload is for a machine identity
+func (p Payload) IsService() bool {
+	return p.Principal == PrincipalService
+}
+
+// WithPrincipalTypes sets the kinds of identity a Decoder accepts tokens for.
+// Only user tokens are accepted unless configured otherwise, so services
+// expecting a user are never handed a service token.
+func WithPrincipalTypes(types ...PrincipalType) DecoderOption {
+	return func(d *Decoder) {
+		d.principalTypes = types
+	}
+}
+
+// allowedPrincipalTypes returns the kinds of identity this Decoder accepts
+func (jwt Decoder) allowedPrincipalTypes() []PrincipalType {
+	if len(jwt.principalTypes) == 0 {
+		return []PrincipalType{PrincipalUser}
+	}
+
+	return jwt.principalTypes
+}
+
+// extractPrincipal sets the identity fields of 'data' from 'claims', checking
+// the claims required for the kind of identity are present
+func (jwt Decoder) extractPrincipal(claims jwtgo.MapClaims, data *Payload) error {
+	principal := PrincipalUser
+	if value, ok := claims[principalTypeClaim]; ok {
+		s, ok := value.(string)
+		if !ok {
+			return ErrInvalidClaim{Name: principalTypeClaim, Err: errors.New("not a string")}
+		}
+		principal = PrincipalType(s)
+	}
+
+	allowed := false
+	for _, t := range jwt.allowedPrincipalTypes() {
+		allowed = allowed || t == principal
+	}
+	if !allowed {
+		return ErrInvalidClaim{Name: principalTypeClaim, Err: fmt.Errorf("principal type '%s' is not allowed", principal)}
+	}
+
+	var err error
+	data.Principal = principal
+	switch principal {
+	case PrincipalUser:
+		data.Customer, err = jwt.extractKey(claims, "accountId")
+		if err != nil {
+			return err
+		}
+		data.RealUser, err = jwt.extractKey(claims, "realUserId")
+		if err != nil {
+			return err
+		}
+		data.EffectiveUser, err = jwt.extractKey(claims, "effectiveUserId")
+		return err
+	case PrincipalService:
+		data.Service, err = jwt.extractKey(claims, serviceNameClaim)
+		if err != nil {
+			return err
+		}
+		if value, ok := claims["accountId"]; ok {
+			if data.Customer, ok = value.(string); !ok {
+				return ErrInvalidClaim{Name: "accountId", Err: errors.New("not a string")}
+			}
+		}
+		// a service token must never be mistaken for a user token
+		for _, name := range []string{"realUserId", "effectiveUserId"} {
+			if _, ok := claims[name]; ok {
+				return ErrInvalidClaim{Name: name, Err: errors.New("not allowed in a service token")}
+			}
+		}
+		return nil
+	default:
+		return ErrInvalidClaim{Name: principalTypeClaim, Err: fmt.Errorf("unknown principal type '%s'", principal)}
+	}
+}
+
+// principalClaims sets the identity claims for 'payload', replacing any
+// identity claims copied from the extra claims
+func principalClaims(payload Payload, claims jwtgo.MapClaims) error {
+	for _, name := range []string{principalTypeClaim, serviceNameClaim, "accountId", "realUserId", "effectiveUserId"} {
+		delete(claims, name)
+	}
+
+	switch {
+	case payload.IsUser():
+		claims["accountId"] = payload.Customer
+		claims["effectiveUserId"] = payload.EffectiveUser
+		claims["realUserId"] = payload.RealUser
+	case payload.IsService():
+		if payload.Service == "" {
+			return ErrMissingClaim{Name: serviceNameClaim}
+		}
+		claims[principalTypeClaim] = string(PrincipalService)
+		claims[serviceNameClaim] = payload.Service
+		if payload.Customer != "" {
+			claims["accountId"] = payload.Customer
+		}
+	default:
+		return fmt.Errorf("unknown principal type '%s'", payload.Principal)
+	}
+
+	return nil
+}
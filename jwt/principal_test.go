@@ -0,0 +1,102 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Principal_Service_Encode_Decode(t *testing.T) {
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithPrincipalTypes(PrincipalUser, PrincipalService))
+	require.Nil(t, err)
+
+	token, err := encoder.Encode(Payload{Principal: PrincipalService, Service: "survey-export-worker", Customer: "abc123"})
+	require.Nil(t, err)
+	payload, err := decoder.Decode(token)
+	assert.Nil(t, err)
+	assert.True(t, payload.IsService())
+	assert.False(t, payload.IsUser())
+	assert.Equal(t, "survey-export-worker", payload.Service)
+	assert.Equal(t, "abc123", payload.Customer)
+	assert.Empty(t, payload.RealUser)
+	assert.NotContains(t, payload.Claims, "realUserId")
+
+	// services don't have to be scoped to an account
+	token, err = encoder.Encode(Payload{Principal: PrincipalService, Service: "nightly-cleanup"})
+	require.Nil(t, err)
+	payload, err = decoder.Decode(token)
+	assert.Nil(t, err)
+	assert.Empty(t, payload.Customer)
+
+	token, err = encoder.Encode(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+	payload, err = decoder.Decode(token)
+	assert.Nil(t, err)
+	assert.True(t, payload.IsUser())
+	assert.Equal(t, PrincipalUser, payload.Principal)
+	assert.Empty(t, payload.Service)
+}
+
+func Test_Principal_Service_NotAllowedByDefault(t *testing.T) {
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	token, err := encoder.Encode(Payload{Principal: PrincipalService, Service: "survey-export-worker"})
+	require.Nil(t, err)
+	_, err = decoder.Decode(token)
+	assert.True(t, errors.Is(err, ErrInvalidClaim{Name: principalTypeClaim}))
+
+	decoder, err = NewDecoderFromPath("jwt.rs256.key.development.pub", WithPrincipalTypes(PrincipalService))
+	require.Nil(t, err)
+	priKey, _ := devKeys(t)
+	_, err = decoder.Decode(signWithKid(t, priKey, ""))
+	assert.True(t, errors.Is(err, ErrInvalidClaim{Name: principalTypeClaim}))
+}
+
+func Test_Principal_Service_Rules(t *testing.T) {
+	priKey, _ := devKeys(t)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithPrincipalTypes(PrincipalUser, PrincipalService))
+	require.Nil(t, err)
+
+	sign := func(claims jwtgo.MapClaims) string {
+		signed, err := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, claims).SignedString(priKey)
+		require.Nil(t, err)
+		return signed
+	}
+
+	cases := map[string]struct {
+		claims   jwtgo.MapClaims
+		expected error
+	}{
+		"missing service name": {jwtgo.MapClaims{"principalType": "service"}, ErrMissingClaim{Name: "serviceName"}},
+		"has user claims":      {jwtgo.MapClaims{"principalType": "service", "serviceName": "worker", "realUserId": "xyz234"}, ErrInvalidClaim{Name: "realUserId"}},
+		"unknown type":         {jwtgo.MapClaims{"principalType": "robot"}, ErrInvalidClaim{Name: "principalType"}},
+		"invalid type":         {jwtgo.MapClaims{"principalType": 1}, ErrInvalidClaim{Name: "principalType"}},
+		"user missing claims":  {jwtgo.MapClaims{"principalType": "user", "accountId": "abc123"}, ErrMissingClaim{Name: "realUserId"}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := decoder.Decode(sign(c.claims))
+			assert.True(t, errors.Is(err, c.expected), err)
+		})
+	}
+}
+
+func Test_Principal_Encode_ServiceRequiresName(t *testing.T) {
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+
+	_, err = encoder.Encode(Payload{Principal: PrincipalService})
+	assert.True(t, errors.Is(err, ErrMissingClaim{Name: "serviceName"}))
+
+	_, err = encoder.Encode(Payload{Principal: "robot"})
+	assert.NotNil(t, err)
+}
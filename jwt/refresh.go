@@ -0,0 +1,288 @@
+package jwt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-errors/errors"
+)
+
+// Refresh tokens last longer than access tokens, so clients can get new access
+// tokens without going back to the identity service
+const defaultRefreshExpiry = 24 * time.Hour
+
+// The claims marking a refresh token, the family of refresh tokens it was
+// rotated from and its identity claims, and the "typ" header of a refresh token
+const (
+	tokenUseClaim        = "token_use"
+	refreshFamilyClaim   = "refresh_family"
+	refreshIdentityClaim = "refresh_identity"
+	tokenUseRefresh      = "refresh"
+	refreshTokenType     = "refresh+jwt"
+)
+
+// refreshIdentityClaims are nested under the "refresh_identity" claim of a
+// refresh token, so decoders that don't know about refresh tokens reject them
+// for missing the identity claims of an access token
+var refreshIdentityClaims = []string{principalTypeClaim, serviceNameClaim, "accountId", "realUserId", "effectiveUserId"}
+
+// TokenPair is a short lived access token and the longer lived refresh token
+// that can be exchanged for a new TokenPair with Encoder.Refresh
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	ExpiresIn        time.Duration
+	RefreshExpiresIn time.Duration
+}
+
+// RefreshStore records the refresh tokens that have been exchanged, so each
+// can only be used once. Entries only need to be kept until 'expiresAt'.
+type RefreshStore interface {
+	// Redeem marks the refresh token with the "jti" claim 'jti' in 'family' as
+	// used. It returns false if the token was already used or the family has
+	// been revoked, and must be atomic so concurrent calls can't both succeed.
+	Redeem(family string, jti string, expiresAt time.Time) (bool, error)
+	// RevokeFamily revokes every refresh token in 'family'
+	RevokeFamily(family string, expiresAt time.Time) error
+}
+
+// WithRefreshExpiry sets how long the refresh token of a TokenPair is valid for
+func WithRefreshExpiry(expiry time.Duration) EncodeOption {
+	return func(c *encodeConfig) {
+		c.refreshExpiry = expiry
+	}
+}
+
+// EncodePair encodes a Payload as an access token and a refresh token. The
+// refresh token can only be used with Encoder.Refresh. It has the "typ" header
+// "refresh+jwt" and its identity claims are nested, so it is rejected by
+// Decoder.Decode, including by versions of this package without refresh
+// tokens.
+func (encoder Encoder) EncodePair(payload Payload, opts ...EncodeOption) (TokenPair, error) {
+	family, err := newTokenID()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return encoder.encodePair(payload, family, opts)
+}
+
+// Refresh exchanges a refresh token for a new TokenPair, verifying it with
+// 'decoder'. Each refresh token can only be used once. If a used refresh token
+// is presented again it has probably been stolen, so every refresh token
+// rotated from the same original TokenPair is revoked and
+// ErrRefreshTokenReused is returned.
+func (encoder Encoder) Refresh(refreshToken string, decoder Decoder, store RefreshStore, opts ...EncodeOption) (TokenPair, error) {
+	payload, err := decoder.DecodeRefresh(refreshToken)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	jti, ok := payload.Claims.String("jti")
+	if !ok || jti == "" {
+		return TokenPair{}, ErrMissingClaim{Name: "jti"}
+	}
+	family, ok := payload.Claims.String(refreshFamilyClaim)
+	if !ok || family == "" {
+		return TokenPair{}, ErrMissingClaim{Name: refreshFamilyClaim}
+	}
+	exp, ok, err := timeClaim(jwtgo.MapClaims(payload.Claims), "exp")
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !ok {
+		return TokenPair{}, ErrMissingClaim{Name: "exp"}
+	}
+
+	redeemed, err := store.Redeem(family, jti, exp)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !redeemed {
+		// the family lives on as long as the newest refresh token rotated from it
+		config := encoder.config(opts)
+		if err := store.RevokeFamily(family, config.clock.Now().Add(config.refreshExpiry)); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrRefreshTokenReused
+	}
+
+	return encoder.encodePair(payload, family, opts)
+}
+
+func (encoder Encoder) encodePair(payload Payload, family string, opts []EncodeOption) (TokenPair, error) {
+	config := encoder.config(opts)
+
+	access := payload
+	access.Claims = Claims{}
+	for name, value := range payload.Claims {
+		if name != tokenUseClaim && name != refreshFamilyClaim && name != refreshIdentityClaim {
+			access.Claims[name] = value
+		}
+	}
+	accessToken, err := encoder.Encode(access, opts...)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	// the refresh token always gets its own jti, as it is used to detect reuse
+	refreshID, err := newTokenID()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refreshToken, err := encoder.Encode(access, append(opts, WithExpiry(config.refreshExpiry), WithIDClaim(refreshID), withRefreshFamily(family))...)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        config.expiry,
+		RefreshExpiresIn: config.refreshExpiry,
+	}, nil
+}
+
+// withRefreshFamily encodes a refresh token in 'family'
+func withRefreshFamily(family string) EncodeOption {
+	return func(c *encodeConfig) {
+		c.refreshFamily = family
+	}
+}
+
+// refreshClaims turns 'claims' into the claims of a refresh token in 'family',
+// nesting its identity claims
+func refreshClaims(claims jwtgo.MapClaims, family string) {
+	identity := map[string]interface{}{}
+	for _, name := range refreshIdentityClaims {
+		if value, ok := claims[name]; ok {
+			identity[name] = value
+			delete(claims, name)
+		}
+	}
+
+	claims[refreshIdentityClaim] = identity
+	claims[tokenUseClaim] = tokenUseRefresh
+	claims[refreshFamilyClaim] = family
+}
+
+// unnestRefreshIdentity moves the identity claims of a refresh token back to
+// the top level of 'claims'
+func unnestRefreshIdentity(claims jwtgo.MapClaims) error {
+	identity, ok := claims[refreshIdentityClaim].(map[string]interface{})
+	if !ok {
+		return ErrMissingClaim{Name: refreshIdentityClaim}
+	}
+
+	delete(claims, refreshIdentityClaim)
+	for _, name := range refreshIdentityClaims {
+		delete(claims, name)
+		if value, ok := identity[name]; ok {
+			claims[name] = value
+		}
+	}
+	return nil
+}
+
+// accountClaim returns the "accountId" claim of a token, which is nested in a
+// refresh token
+func accountClaim(claims jwtgo.MapClaims) string {
+	if claims[tokenUseClaim] == tokenUseRefresh {
+		identity, _ := claims[refreshIdentityClaim].(map[string]interface{})
+		accountID, _ := identity["accountId"].(string)
+		return accountID
+	}
+
+	accountID, _ := claims["accountId"].(string)
+	return accountID
+}
+
+// DecodeRefresh decodes a refresh token issued by Encoder.EncodePair and
+// returns the Payload. Access tokens are rejected.
+func (jwt Decoder) DecodeRefresh(tokenString string) (Payload, error) {
+	return jwt.decode(tokenString, tokenUseRefresh)
+}
+
+// checkTokenUse checks a token with 'header' and 'claims' can be used for
+// 'use', so refresh tokens can't be used as access tokens or the other way
+// around. Access tokens may have any "token_use" except refresh, eg. "access"
+// from other issuers.
+func checkTokenUse(header map[string]interface{}, claims jwtgo.MapClaims, use string) error {
+	actual, _ := claims[tokenUseClaim].(string)
+	refreshType := header["typ"] == refreshTokenType
+	if use == "" {
+		if actual == tokenUseRefresh || refreshType {
+			return ErrInvalidClaim{Name: tokenUseClaim, Err: errors.New("refresh tokens can't be used as access tokens")}
+		}
+		return nil
+	}
+
+	if actual != use || refreshType != (use == tokenUseRefresh) {
+		return ErrInvalidClaim{Name: tokenUseClaim, Err: fmt.Errorf("expected a %s token", use)}
+	}
+	return nil
+}
+
+// MemoryRefreshStore is a RefreshStore held in memory. Entries are forgotten
+// once the refresh tokens they cover would have expired.
+type MemoryRefreshStore struct {
+	mu       sync.Mutex
+	redeemed map[string]time.Time
+	revoked  map[string]time.Time
+	clock    Clock
+}
+
+// NewMemoryRefreshStore creates an empty MemoryRefreshStore
+func NewMemoryRefreshStore(opts ...StoreOption) *MemoryRefreshStore {
+	return &MemoryRefreshStore{
+		redeemed: map[string]time.Time{},
+		revoked:  map[string]time.Time{},
+		clock:    newStoreConfig(opts).clock,
+	}
+}
+
+// Redeem implements RefreshStore
+func (s *MemoryRefreshStore) Redeem(family string, jti string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.purge(now)
+	if revokedUntil, ok := s.revoked[family]; ok && now.Before(revokedUntil) {
+		return false, nil
+	}
+	if _, ok := s.redeemed[jti]; ok {
+		return false, nil
+	}
+
+	s.redeemed[jti] = expiresAt
+	return true, nil
+}
+
+// RevokeFamily implements RefreshStore
+func (s *MemoryRefreshStore) RevokeFamily(family string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge(s.clock.Now())
+	if existing, ok := s.revoked[family]; !ok || expiresAt.After(existing) {
+		s.revoked[family] = expiresAt
+	}
+	return nil
+}
+
+// purge removes the entries that have expired at 'now'. Callers must hold mu.
+func (s *MemoryRefreshStore) purge(now time.Time) {
+	for jti, expiresAt := range s.redeemed {
+		if !now.Before(expiresAt) {
+			delete(s.redeemed, jti)
+		}
+	}
+	for family, expiresAt := range s.revoked {
+		if !now.Before(expiresAt) {
+			delete(s.revoked, family)
+		}
+	}
+}
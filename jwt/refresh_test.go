@@ -0,0 +1,205 @@
+package jwt
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pairEncoderDecoder(t *testing.T) (Encoder, Decoder) {
+	t.Helper()
+	encoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem", WithRefreshExpiry(time.Hour))
+	require.Nil(t, err)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+	return encoder, decoder
+}
+
+func Test_Refresh_EncodePair(t *testing.T) {
+	encoder, decoder := pairEncoderDecoder(t)
+	payload := Payload{
+		Customer:      "abc123",
+		RealUser:      "xyz234",
+		EffectiveUser: "xyz345",
+		Claims:        Claims{"email": "someone@example.com"},
+	}
+
+	pair, err := encoder.EncodePair(payload)
+	require.Nil(t, err)
+	assert.Equal(t, defaultExpiry, pair.ExpiresIn)
+	assert.Equal(t, time.Hour, pair.RefreshExpiresIn)
+
+	access, err := decoder.Decode(pair.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", access.Customer)
+	assert.Equal(t, "someone@example.com", access.Claims["email"])
+	assert.NotContains(t, access.Claims, tokenUseClaim)
+
+	// each token can only be used for its own purpose
+	_, err = decoder.Decode(pair.RefreshToken)
+	assert.True(t, errors.Is(err, ErrInvalidClaim{Name: tokenUseClaim}))
+	_, err = decoder.DecodeRefresh(pair.AccessToken)
+	assert.True(t, errors.Is(err, ErrInvalidClaim{Name: tokenUseClaim}))
+
+	refresh, err := decoder.DecodeRefresh(pair.RefreshToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "xyz345", refresh.EffectiveUser)
+	assert.NotEqual(t, access.Claims["jti"], refresh.Claims["jti"])
+}
+
+func Test_Refresh_Rotation(t *testing.T) {
+	encoder, decoder := pairEncoderDecoder(t)
+	store := NewMemoryRefreshStore()
+
+	pair, err := encoder.EncodePair(Payload{
+		Customer:      "abc123",
+		RealUser:      "xyz234",
+		EffectiveUser: "xyz345",
+		Claims:        Claims{"email": "someone@example.com"},
+	})
+	require.Nil(t, err)
+
+	rotated, err := encoder.Refresh(pair.RefreshToken, decoder, store)
+	require.Nil(t, err)
+	assert.NotEqual(t, pair.RefreshToken, rotated.RefreshToken)
+
+	access, err := decoder.Decode(rotated.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", access.Customer)
+	assert.Equal(t, "someone@example.com", access.Claims["email"])
+
+	rotatedAgain, err := encoder.Refresh(rotated.RefreshToken, decoder, store)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, rotatedAgain.AccessToken)
+}
+
+func Test_Refresh_ReuseRevokesFamily(t *testing.T) {
+	encoder, decoder := pairEncoderDecoder(t)
+	store := NewMemoryRefreshStore()
+
+	pair, err := encoder.EncodePair(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+	other, err := encoder.EncodePair(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	rotated, err := encoder.Refresh(pair.RefreshToken, decoder, store)
+	require.Nil(t, err)
+
+	// the original refresh token has been stolen and replayed
+	_, err = encoder.Refresh(pair.RefreshToken, decoder, store)
+	assert.True(t, errors.Is(err, ErrRefreshTokenReused))
+
+	// so the legitimate client's rotated token no longer works either
+	_, err = encoder.Refresh(rotated.RefreshToken, decoder, store)
+	assert.True(t, errors.Is(err, ErrRefreshTokenReused))
+
+	// other families are unaffected
+	_, err = encoder.Refresh(other.RefreshToken, decoder, store)
+	assert.Nil(t, err)
+}
+
+func Test_Refresh_ConcurrentRedeem(t *testing.T) {
+	encoder, decoder := pairEncoderDecoder(t)
+	store := NewMemoryRefreshStore()
+	pair, err := encoder.EncodePair(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := encoder.Refresh(pair.RefreshToken, decoder, store); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, succeeded)
+}
+
+func Test_Refresh_InvalidToken(t *testing.T) {
+	encoder, decoder := pairEncoderDecoder(t)
+	store := NewMemoryRefreshStore()
+
+	_, err := encoder.Refresh("not-a-jwt", decoder, store)
+	assert.True(t, errors.Is(err, ErrMalformed))
+
+	pair, err := encoder.EncodePair(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"}, WithRefreshExpiry(-time.Minute))
+	require.Nil(t, err)
+	_, err = encoder.Refresh(pair.RefreshToken, decoder, store)
+	assert.True(t, errors.Is(err, ErrTokenExpired))
+}
+
+func Test_Refresh_OtherTokenUse(t *testing.T) {
+	priKey, _ := devKeys(t)
+	_, decoder := pairEncoderDecoder(t)
+
+	// access tokens from other issuers, eg. Cognito, set their own token_use
+	token := signClaims(t, priKey, map[string]interface{}{tokenUseClaim: "access"})
+	_, err := decoder.Decode(token)
+	assert.Nil(t, err)
+	_, err = decoder.DecodeRefresh(token)
+	assert.True(t, errors.Is(err, ErrInvalidClaim{Name: tokenUseClaim}))
+}
+
+func Test_Refresh_LegacyDecoders(t *testing.T) {
+	encoder, _ := pairEncoderDecoder(t)
+	pair, err := encoder.EncodePair(Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"})
+	require.Nil(t, err)
+
+	// decoders without refresh tokens require the identity claims at the top
+	// level, and ignore "token_use" and "typ"
+	parsed, _, err := new(jwtgo.Parser).ParseUnverified(pair.RefreshToken, jwtgo.MapClaims{})
+	require.Nil(t, err)
+	assert.Equal(t, refreshTokenType, parsed.Header["typ"])
+	claims := parsed.Claims.(jwtgo.MapClaims)
+	for _, name := range []string{"accountId", "realUserId", "effectiveUserId"} {
+		assert.NotContains(t, claims, name)
+	}
+	assert.Equal(t, "abc123", claims[refreshIdentityClaim].(map[string]interface{})["accountId"])
+}
+
+func Test_Refresh_TokenType(t *testing.T) {
+	priKey, _ := devKeys(t)
+	_, decoder := pairEncoderDecoder(t)
+
+	// the "typ" header alone marks a refresh token
+	token := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, jwtgo.MapClaims{
+		"accountId":       "abc123",
+		"realUserId":      "xyz234",
+		"effectiveUserId": "xyz345",
+	})
+	token.Header["typ"] = refreshTokenType
+	signed, err := token.SignedString(priKey)
+	require.Nil(t, err)
+
+	_, err = decoder.Decode(signed)
+	assert.True(t, errors.Is(err, ErrInvalidClaim{Name: tokenUseClaim}))
+	_, err = decoder.DecodeRefresh(signed)
+	assert.True(t, errors.Is(err, ErrInvalidClaim{Name: tokenUseClaim}))
+}
+
+func Test_MemoryRefreshStore_Expires(t *testing.T) {
+	store := NewMemoryRefreshStore()
+
+	ok, err := store.Redeem("family", "jti", time.Now().Add(-time.Second))
+	require.Nil(t, err)
+	assert.True(t, ok)
+	require.Nil(t, store.RevokeFamily("family", time.Now().Add(-time.Second)))
+
+	// both entries have expired, so are forgotten
+	ok, err = store.Redeem("family", "jti", time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
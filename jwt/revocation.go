@@ -0,0 +1,378 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+)
+
+// RevocationStore records tokens that have been revoked before they expire,
+// eg. when a user is offboarded or an impersonation session is ended.
+//
+// Revocations only need to be kept until the tokens they cover would have
+// expired anyway, so every entry has an expiry after which stores may forget it.
+type RevocationStore interface {
+	// RevokeToken revokes the token with the "jti" claim 'jti'. 'expiresAt'
+	// should be the "exp" claim of the token.
+	RevokeToken(jti string, expiresAt time.Time) error
+	// RevokeSubject revokes every token for 'subject' issued before
+	// 'issuedBefore'. 'expiresAt' should be the latest time any such token
+	// could expire, eg. issuedBefore plus the longest token lifetime.
+	RevokeSubject(subject string, issuedBefore time.Time, expiresAt time.Time) error
+	// IsTokenRevoked returns true if the token with the "jti" claim 'jti' is revoked
+	IsTokenRevoked(jti string) (bool, error)
+	// SubjectRevokedBefore returns the time before which tokens for 'subject'
+	// are revoked, and false if there is no such revocation
+	SubjectRevokedBefore(subject string) (time.Time, bool, error)
+}
+
+// WithRevocationStore rejects tokens revoked in 'store'. A token is revoked
+// if its "jti" claim is revoked, or if it was issued before a revocation for
+// its "sub" claim, real user, effective user or service name.
+func WithRevocationStore(store RevocationStore) DecoderOption {
+	return func(d *Decoder) {
+		d.revocations = store
+	}
+}
+
+// RevokePayload revokes the decoded token 'payload' in 'store', using its
+// "jti" and "exp" claims
+func RevokePayload(store RevocationStore, payload Payload) error {
+	jti, ok := payload.Claims.String("jti")
+	if !ok || jti == "" {
+		return ErrMissingClaim{Name: "jti"}
+	}
+
+	exp, ok, err := timeClaim(jwtgo.MapClaims(payload.Claims), "exp")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMissingClaim{Name: "exp"}
+	}
+
+	return store.RevokeToken(jti, exp)
+}
+
+// checkRevoked returns ErrTokenRevoked if the token with 'claims' has been revoked
+func (jwt Decoder) checkRevoked(claims jwtgo.MapClaims) error {
+	if jwt.revocations == nil {
+		return nil
+	}
+
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		revoked, err := jwt.revocations.IsTokenRevoked(jti)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+
+	iat, hasIat, err := timeClaim(claims, "iat")
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"sub", "realUserId", "effectiveUserId", serviceNameClaim} {
+		subject, ok := claims[name].(string)
+		if !ok || subject == "" {
+			continue
+		}
+
+		before, revoked, err := jwt.revocations.SubjectRevokedBefore(subject)
+		if err != nil {
+			return err
+		}
+		// without an "iat" claim we can't tell when the token was issued, so
+		// it is treated as revoked
+		if revoked && (!hasIat || iat.Before(before)) {
+			return ErrTokenRevoked
+		}
+	}
+
+	return nil
+}
+
+// subjectRevocation revokes the tokens for a subject issued before IssuedBefore
+type subjectRevocation struct {
+	IssuedBefore time.Time `json:"issuedBefore"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// revocationList is the set of revocations held by a store
+type revocationList struct {
+	Tokens   map[string]time.Time         `json:"tokens"`
+	Subjects map[string]subjectRevocation `json:"subjects"`
+}
+
+func newRevocationList() revocationList {
+	return revocationList{
+		Tokens:   map[string]time.Time{},
+		Subjects: map[string]subjectRevocation{},
+	}
+}
+
+// purge removes the revocations that have expired at 'now'
+func (l revocationList) purge(now time.Time) {
+	for jti, expiresAt := range l.Tokens {
+		if !now.Before(expiresAt) {
+			delete(l.Tokens, jti)
+		}
+	}
+	for subject, r := range l.Subjects {
+		if !now.Before(r.ExpiresAt) {
+			delete(l.Subjects, subject)
+		}
+	}
+}
+
+func (l revocationList) isTokenRevoked(jti string, now time.Time) bool {
+	expiresAt, ok := l.Tokens[jti]
+	return ok && now.Before(expiresAt)
+}
+
+func (l revocationList) subjectRevokedBefore(subject string, now time.Time) (time.Time, bool) {
+	r, ok := l.Subjects[subject]
+	if !ok || !now.Before(r.ExpiresAt) {
+		return time.Time{}, false
+	}
+
+	return r.IssuedBefore, true
+}
+
+func (l revocationList) revokeSubject(subject string, issuedBefore time.Time, expiresAt time.Time) {
+	// keep the widest revocation if the subject is revoked more than once
+	if existing, ok := l.Subjects[subject]; ok {
+		if existing.IssuedBefore.After(issuedBefore) {
+			issuedBefore = existing.IssuedBefore
+		}
+		if existing.ExpiresAt.After(expiresAt) {
+			expiresAt = existing.ExpiresAt
+		}
+	}
+
+	l.Subjects[subject] = subjectRevocation{
+		IssuedBefore: issuedBefore,
+		ExpiresAt:    expiresAt,
+	}
+}
+
+// MemoryRevocationStore is a RevocationStore held in memory. Revocations are
+// forgotten once the tokens they cover would have expired.
+type MemoryRevocationStore struct {
+	mu    sync.RWMutex
+	list  revocationList
+	clock Clock
+}
+
+// NewMemoryRevocationStore creates an empty MemoryRevocationStore
+func NewMemoryRevocationStore(opts ...StoreOption) *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		list:  newRevocationList(),
+		clock: newStoreConfig(opts).clock,
+	}
+}
+
+// RevokeToken implements RevocationStore
+func (s *MemoryRevocationStore) RevokeToken(jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.list.purge(s.clock.Now())
+	s.list.Tokens[jti] = expiresAt
+	return nil
+}
+
+// RevokeSubject implements RevocationStore
+func (s *MemoryRevocationStore) RevokeSubject(subject string, issuedBefore time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.list.purge(s.clock.Now())
+	s.list.revokeSubject(subject, issuedBefore, expiresAt)
+	return nil
+}
+
+// IsTokenRevoked implements RevocationStore
+func (s *MemoryRevocationStore) IsTokenRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.list.isTokenRevoked(jti, s.clock.Now()), nil
+}
+
+// SubjectRevokedBefore implements RevocationStore
+func (s *MemoryRevocationStore) SubjectRevokedBefore(subject string) (time.Time, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	before, ok := s.list.subjectRevokedBefore(subject, s.clock.Now())
+	return before, ok, nil
+}
+
+// FileRevocationStore is a RevocationStore persisted as JSON in a file, so
+// revocations survive restarts and can be shared through a mounted volume.
+// Revocations hold an exclusive lock on a ".lock" file next to it while they
+// read, update and replace the file, so processes sharing it don't lose each
+// other's revocations. Lookups re-read the file at most once a second by
+// default, so a revocation by another process can take that long to apply.
+type FileRevocationStore struct {
+	path     string
+	clock    Clock
+	interval time.Duration
+
+	mu        sync.Mutex
+	list      revocationList
+	data      []byte // the file contents 'list' was read from
+	checkedAt time.Time
+}
+
+// NewFileRevocationStore creates a FileRevocationStore backed by the file at
+// 'path', which is created on the first revocation if it does not exist
+func NewFileRevocationStore(path string, opts ...StoreOption) (*FileRevocationStore, error) {
+	config := newStoreConfig(opts)
+	s := &FileRevocationStore{
+		path:     filepath.Clean(path),
+		clock:    config.clock,
+		interval: config.reloadInterval,
+		list:     newRevocationList(),
+	}
+
+	if err := s.reload(true); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RevokeToken implements RevocationStore
+func (s *FileRevocationStore) RevokeToken(jti string, expiresAt time.Time) error {
+	return s.update(func(list revocationList) {
+		list.Tokens[jti] = expiresAt
+	})
+}
+
+// RevokeSubject implements RevocationStore
+func (s *FileRevocationStore) RevokeSubject(subject string, issuedBefore time.Time, expiresAt time.Time) error {
+	return s.update(func(list revocationList) {
+		list.revokeSubject(subject, issuedBefore, expiresAt)
+	})
+}
+
+// IsTokenRevoked implements RevocationStore
+func (s *FileRevocationStore) IsTokenRevoked(jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(false); err != nil {
+		return false, err
+	}
+	return s.list.isTokenRevoked(jti, s.clock.Now()), nil
+}
+
+// SubjectRevokedBefore implements RevocationStore
+func (s *FileRevocationStore) SubjectRevokedBefore(subject string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(false); err != nil {
+		return time.Time{}, false, err
+	}
+	before, ok := s.list.subjectRevokedBefore(subject, s.clock.Now())
+	return before, ok, nil
+}
+
+// update applies 'revoke' to the latest revocations in the file and saves
+// them, holding the lock file so no other process updates it in between
+func (s *FileRevocationStore) update(revoke func(list revocationList)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.reload(true); err != nil {
+		return err
+	}
+	revoke(s.list)
+	return s.save()
+}
+
+// reload re-reads the file if it has changed since it was last read. Unless
+// 'force' is true the file is only checked once per reload interval. Callers
+// must hold mu.
+func (s *FileRevocationStore) reload(force bool) error {
+	now := s.clock.Now()
+	if !force && now.Sub(s.checkedAt) < s.interval && !now.Before(s.checkedAt) {
+		return nil
+	}
+	s.checkedAt = now
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// compare the contents, as a rewrite may not change the size or mtime
+	if bytes.Equal(data, s.data) {
+		return nil
+	}
+
+	list := newRevocationList()
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	if list.Tokens == nil {
+		list.Tokens = map[string]time.Time{}
+	}
+	if list.Subjects == nil {
+		list.Subjects = map[string]subjectRevocation{}
+	}
+
+	s.list = list
+	s.data = data
+	return nil
+}
+
+// save purges expired revocations and writes the rest to the file, replacing
+// it atomically so readers never see a partial write. Callers must hold mu
+// and the lock file.
+func (s *FileRevocationStore) save() error {
+	s.list.purge(s.clock.Now())
+
+	data, err := json.Marshal(s.list)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	s.data = data
+	return nil
+}
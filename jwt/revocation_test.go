@@ -0,0 +1,187 @@
+package jwt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func revocationStores(t *testing.T) map[string]RevocationStore {
+	t.Helper()
+	fileStore, err := NewFileRevocationStore(filepath.Join(t.TempDir(), "revoked.json"))
+	require.Nil(t, err)
+
+	return map[string]RevocationStore{
+		"memory": NewMemoryRevocationStore(),
+		"file":   fileStore,
+	}
+}
+
+func Test_Revocation_Token(t *testing.T) {
+	jwtEncoder, err := NewEncoderFromPath("jwt.rs256.key.development.pem")
+	require.Nil(t, err)
+
+	for name, store := range revocationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			jwtDecoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithRevocationStore(store))
+			require.Nil(t, err)
+
+			payload := Payload{Customer: "abc123", RealUser: "xyz234", EffectiveUser: "xyz345"}
+			revoked, err := jwtEncoder.Encode(payload)
+			require.Nil(t, err)
+			other, err := jwtEncoder.Encode(payload)
+			require.Nil(t, err)
+
+			decoded, err := jwtDecoder.Decode(revoked)
+			require.Nil(t, err)
+			assert.Nil(t, RevokePayload(store, decoded))
+
+			_, err = jwtDecoder.Decode(revoked)
+			assert.True(t, errors.Is(err, ErrTokenRevoked))
+
+			_, err = jwtDecoder.Decode(other)
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func Test_Revocation_Subject(t *testing.T) {
+	priKey, _ := devKeys(t)
+	now := time.Now()
+	before := signClaims(t, priKey, jwtgo.MapClaims{"iat": now.Add(-time.Hour).Unix()})
+	after := signClaims(t, priKey, jwtgo.MapClaims{"iat": now.Add(-time.Second).Unix()})
+	noIat := signClaims(t, priKey, nil)
+
+	for name, store := range revocationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			jwtDecoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithRevocationStore(store))
+			require.Nil(t, err)
+
+			// offboard the real user "xyz234"
+			assert.Nil(t, store.RevokeSubject("xyz234", now.Add(-time.Minute), now.Add(time.Hour)))
+
+			_, err = jwtDecoder.Decode(before)
+			assert.True(t, errors.Is(err, ErrTokenRevoked))
+			_, err = jwtDecoder.Decode(noIat)
+			assert.True(t, errors.Is(err, ErrTokenRevoked))
+			_, err = jwtDecoder.Decode(after)
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func Test_Revocation_Expires(t *testing.T) {
+	for name, store := range revocationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.RevokeToken("expired", time.Now().Add(-time.Second)))
+			assert.Nil(t, store.RevokeToken("current", time.Now().Add(time.Hour)))
+			assert.Nil(t, store.RevokeSubject("gone", time.Now(), time.Now().Add(-time.Second)))
+
+			revoked, err := store.IsTokenRevoked("expired")
+			assert.Nil(t, err)
+			assert.False(t, revoked)
+
+			revoked, err = store.IsTokenRevoked("current")
+			assert.Nil(t, err)
+			assert.True(t, revoked)
+
+			_, revoked, err = store.SubjectRevokedBefore("gone")
+			assert.Nil(t, err)
+			assert.False(t, revoked)
+		})
+	}
+}
+
+func Test_Revocation_FileStore_Shared(t *testing.T) {
+	clock := newTestClock()
+	path := filepath.Join(t.TempDir(), "revoked.json")
+	writer, err := NewFileRevocationStore(path, WithStoreClock(clock))
+	require.Nil(t, err)
+	assert.Nil(t, writer.RevokeToken("abc", clock.Now().Add(time.Hour)))
+
+	// a second process sharing the file, eg. through a mounted volume
+	reader, err := NewFileRevocationStore(path, WithStoreClock(clock))
+	require.Nil(t, err)
+	revoked, err := reader.IsTokenRevoked("abc")
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+
+	// the file is only re-read once per reload interval
+	assert.Nil(t, writer.RevokeSubject("xyz234", clock.Now(), clock.Now().Add(time.Hour)))
+	_, revoked, err = reader.SubjectRevokedBefore("xyz234")
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	clock.advance(defaultStoreReloadInterval)
+	_, revoked, err = reader.SubjectRevokedBefore("xyz234")
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+}
+
+func Test_Revocation_FileStore_SameSizeRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.json")
+	store, err := NewFileRevocationStore(path, WithStoreReloadInterval(0))
+	require.Nil(t, err)
+	assert.Nil(t, store.RevokeToken("abc", time.Now().Add(time.Hour)))
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+
+	// rewrite the file in place, keeping its size and mtime
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	require.Nil(t, ioutil.WriteFile(path, bytes.Replace(data, []byte(`"abc"`), []byte(`"abd"`), 1), 0600))
+	require.Nil(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+
+	revoked, err := store.IsTokenRevoked("abd")
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+}
+
+func Test_Revocation_FileStore_ConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.json")
+
+	// each store stands in for a process sharing the file
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		store, err := NewFileRevocationStore(path)
+		require.Nil(t, err)
+		wg.Add(1)
+		go func(i int, store *FileRevocationStore) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.Nil(t, store.RevokeToken(fmt.Sprintf("token-%d-%d", i, j), time.Now().Add(time.Hour)))
+			}
+		}(i, store)
+	}
+	wg.Wait()
+
+	store, err := NewFileRevocationStore(path)
+	require.Nil(t, err)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 10; j++ {
+			revoked, err := store.IsTokenRevoked(fmt.Sprintf("token-%d-%d", i, j))
+			assert.Nil(t, err)
+			assert.True(t, revoked, "token-%d-%d", i, j)
+		}
+	}
+}
+
+func Test_Revocation_RevokePayload_MissingClaims(t *testing.T) {
+	store := NewMemoryRevocationStore()
+
+	err := RevokePayload(store, Payload{Claims: Claims{}})
+	assert.True(t, errors.Is(err, ErrMissingClaim{Name: "jti"}))
+
+	err = RevokePayload(store, Payload{Claims: Claims{"jti": "abc"}})
+	assert.True(t, errors.Is(err, ErrMissingClaim{Name: "exp"}))
+}
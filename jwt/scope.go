@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"strings"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-errors/errors"
+)
+
+const (
+	// ScopeClaim holds the scopes granted to a token as a space separated
+	// string (RFC 8693 section 4.2)
+	ScopeClaim = "scope"
+	// PermissionsClaim holds scopes as an array of strings, as issued by
+	// some identity providers. Decoded tokens combine both claims.
+	PermissionsClaim = "permissions"
+)
+
+// scopeSeparator separates the levels of a hierarchical scope, eg.
+// "surveys:responses:read"
+const scopeSeparator = ":"
+
+// HasScope returns true if the token grants 'required'. Scopes are
+// hierarchical, so a granted scope also grants everything beneath it: a token
+// with "surveys" has "surveys:write". A "*" level in a granted scope matches
+// any value at that level, so "surveys:*" grants "surveys:write" and "*"
+// grants everything.
+func (p Payload) HasScope(required string) bool {
+	for _, granted := range p.Scopes {
+		if scopeMatches(granted, required) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasScopes returns true if the token grants every scope in 'required'
+func (p Payload) HasScopes(required ...string) bool {
+	for _, scope := range required {
+		if !p.HasScope(scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// scopeMatches returns true if the scope 'granted' grants 'required'
+func scopeMatches(granted string, required string) bool {
+	if granted == "" || required == "" {
+		return false
+	}
+
+	grantedLevels := strings.Split(granted, scopeSeparator)
+	requiredLevels := strings.Split(required, scopeSeparator)
+	if len(grantedLevels) > len(requiredLevels) {
+		return false
+	}
+
+	for i, level := range grantedLevels {
+		if level != "*" && level != requiredLevels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// extractScopes reads the scopes granted by the "scope" and "permissions"
+// claims, in that order and without duplicates
+func extractScopes(claims jwtgo.MapClaims) ([]string, error) {
+	var scopes []string
+	seen := map[string]bool{}
+	add := func(scope string) {
+		if scope != "" && !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	switch value := claims[ScopeClaim].(type) {
+	case nil:
+	case string:
+		for _, scope := range strings.Fields(value) {
+			add(scope)
+		}
+	default:
+		// some issuers use an array despite RFC 8693
+		values, ok := Claims(claims).Strings(ScopeClaim)
+		if !ok {
+			return nil, ErrInvalidClaim{Name: ScopeClaim, Err: errors.New("not a string or array of strings")}
+		}
+		for _, scope := range values {
+			add(scope)
+		}
+	}
+
+	if _, ok := claims[PermissionsClaim]; ok {
+		values, ok := Claims(claims).Strings(PermissionsClaim)
+		if !ok {
+			return nil, ErrInvalidClaim{Name: PermissionsClaim, Err: errors.New("not an array of strings")}
+		}
+		for _, scope := range values {
+			add(scope)
+		}
+	}
+
+	return scopes, nil
+}
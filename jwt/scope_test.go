@@ -0,0 +1,115 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Scope_Matches(t *testing.T) {
+	cases := []struct {
+		granted  string
+		required string
+		matches  bool
+	}{
+		{"surveys:write", "surveys:write", true},
+		{"surveys:read", "surveys:write", false},
+		{"surveys", "surveys:write", true},
+		{"surveys", "surveys:responses:read", true},
+		{"surveys:write", "surveys", false},
+		{"surveys:*", "surveys:write", true},
+		{"surveys:*", "surveys:responses:read", true},
+		{"surveys:*", "surveys", false},
+		{"*", "surveys:write", true},
+		{"*:read", "surveys:read", true},
+		{"*:read", "surveys:write", false},
+		{"survey", "surveys:write", false},
+		{"surveys:write", "*", false},
+		{"", "surveys", false},
+		{"surveys", "", false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.matches, scopeMatches(c.granted, c.required), "%s grants %s", c.granted, c.required)
+	}
+}
+
+func Test_Scope_HasScopes(t *testing.T) {
+	payload := Payload{Scopes: []string{"surveys:read", "reports"}}
+
+	assert.True(t, payload.HasScope("surveys:read"))
+	assert.True(t, payload.HasScope("reports:export"))
+	assert.False(t, payload.HasScope("surveys:write"))
+	assert.True(t, payload.HasScopes("surveys:read", "reports:export"))
+	assert.False(t, payload.HasScopes("surveys:read", "surveys:write"))
+	assert.True(t, payload.HasScopes())
+	assert.False(t, Payload{}.HasScope("surveys:read"))
+}
+
+func Test_Scope_EncodeDecode(t *testing.T) {
+	encoder, decoder := introspectionKeys(t)
+
+	token, err := encoder.Encode(Payload{
+		Customer:      "abc123",
+		RealUser:      "xyz234",
+		EffectiveUser: "xyz345",
+		Scopes:        []string{"surveys:read", "surveys:write"},
+	})
+	require.Nil(t, err)
+
+	payload, err := decoder.Decode(token)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"surveys:read", "surveys:write"}, payload.Scopes)
+	assert.Equal(t, "surveys:read surveys:write", payload.Claims[ScopeClaim])
+}
+
+func Test_Scope_Claims(t *testing.T) {
+	priKey, _ := devKeys(t)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	cases := map[string]struct {
+		claims jwtgo.MapClaims
+		scopes []string
+		err    error
+	}{
+		"none": {
+			claims: jwtgo.MapClaims{},
+		},
+		"scope string": {
+			claims: jwtgo.MapClaims{"scope": "surveys:read  reports"},
+			scopes: []string{"surveys:read", "reports"},
+		},
+		"scope array": {
+			claims: jwtgo.MapClaims{"scope": []string{"surveys:read"}},
+			scopes: []string{"surveys:read"},
+		},
+		"permissions": {
+			claims: jwtgo.MapClaims{"scope": "surveys:read", "permissions": []string{"reports", "surveys:read"}},
+			scopes: []string{"surveys:read", "reports"},
+		},
+		"invalid scope": {
+			claims: jwtgo.MapClaims{"scope": 42},
+			err:    ErrInvalidClaim{Name: ScopeClaim},
+		},
+		"invalid permissions": {
+			claims: jwtgo.MapClaims{"permissions": "reports"},
+			err:    ErrInvalidClaim{Name: PermissionsClaim},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			payload, err := decoder.Decode(signClaims(t, priKey, c.claims))
+			if c.err != nil {
+				assert.True(t, errors.Is(err, c.err), "got %v", err)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, c.scopes, payload.Scopes)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package jwt
+
+import (
+	"crypto"
+	"fmt"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-errors/errors"
+)
+
+// Signer signs the tokens created by an Encoder. Implementations can keep the
+// private key out of the process, eg. in a KMS or HSM, see KMSSigner.
+type Signer interface {
+	// Algorithm returns the JWS algorithm of the signatures, eg. "RS256",
+	// which is set as the "alg" header
+	Algorithm() string
+	// Public returns the public key that verifies the signatures
+	Public() crypto.PublicKey
+	// Sign returns the JWS signature of 'signingInput', the encoded header and
+	// claims joined by "."
+	Sign(signingInput []byte) ([]byte, error)
+}
+
+// snapshotSigner is a Signer whose key can change while it is in use, eg.
+// when it is reloaded from a ReloadableKeySource
+type snapshotSigner interface {
+	// snapshot returns a Signer for the current key, so a token is signed by
+	// the key matching its "alg" header
+	snapshot() Signer
+}
+
+// KeySigner is a Signer holding the private key in process memory
+type KeySigner struct {
+	key loadedKey
+}
+
+// NewKeySigner creates a Signer for the RSA, ECDSA or Ed25519 private key
+// 'key'. It signs with RS256, ES256/ES384/ES512 depending on the curve, or
+// EdDSA.
+func NewKeySigner(key crypto.Signer) (KeySigner, error) {
+	alg, err := algorithmForKey(key)
+	if err != nil {
+		return KeySigner{}, err
+	}
+
+	return KeySigner{key: loadedKey{key: key, alg: alg}}, nil
+}
+
+// Algorithm implements Signer
+func (s KeySigner) Algorithm() string {
+	return s.key.alg
+}
+
+// Public implements Signer
+func (s KeySigner) Public() crypto.PublicKey {
+	signer, ok := s.key.key.(crypto.Signer)
+	if !ok {
+		return nil
+	}
+
+	return signer.Public()
+}
+
+// Sign implements Signer
+func (s KeySigner) Sign(signingInput []byte) ([]byte, error) {
+	method := jwtgo.GetSigningMethod(s.key.alg)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported signing algorithm '%s'", s.key.alg)
+	}
+
+	signature, err := method.Sign(string(signingInput), s.key.key)
+	if err != nil {
+		return nil, err
+	}
+	return jwtgo.DecodeSegment(signature)
+}
+
+// snapshot implements snapshotSigner, so the sourceKey of an Encoder created
+// from a KeySource is used as its Signer
+func (k *sourceKey) snapshot() Signer {
+	return KeySigner{key: k.get()}
+}
+
+func (k *sourceKey) Algorithm() string {
+	return k.snapshot().Algorithm()
+}
+
+func (k *sourceKey) Public() crypto.PublicKey {
+	return k.snapshot().Public()
+}
+
+func (k *sourceKey) Sign(signingInput []byte) ([]byte, error) {
+	return k.snapshot().Sign(signingInput)
+}
+
+// sign signs 'token' with 'signer', returning the compact serialization
+func sign(token *jwtgo.Token, signer Signer) (string, error) {
+	signingInput, err := token.SigningString()
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := signer.Sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	if len(signature) == 0 {
+		return "", errors.New("signer returned an empty signature")
+	}
+
+	return signingInput + "." + jwtgo.EncodeSegment(signature), nil
+}
@@ -0,0 +1,110 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-errors/errors"
+)
+
+// validateClaims checks the registered claims of a verified token against
+// 'now' and the expectations configured on the Decoder
+func (jwt Decoder) validateClaims(claims jwtgo.MapClaims, now time.Time) error {
+	for _, name := range jwt.requiredClaims {
+		if _, ok := claims[name]; !ok {
+			return ErrMissingClaim{Name: name}
+		}
+	}
+
+	exp, ok, err := timeClaim(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if ok && now.After(exp.Add(jwt.leeway)) {
+		return ErrInvalidClaim{Name: "exp", Err: ErrTokenExpired}
+	}
+
+	nbf, ok, err := timeClaim(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(jwt.leeway).Before(nbf) {
+		return ErrInvalidClaim{Name: "nbf", Err: ErrTokenNotValidYet}
+	}
+
+	iat, ok, err := timeClaim(claims, "iat")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(jwt.leeway).Before(iat) {
+		return ErrInvalidClaim{Name: "iat", Err: ErrTokenUsedBeforeIssued}
+	}
+
+	if jwt.issuer != "" {
+		iss, _ := claims["iss"].(string)
+		if iss != jwt.issuer {
+			return ErrInvalidClaim{Name: "iss", Err: fmt.Errorf("expected %s, got '%s'", jwt.issuer, iss)}
+		}
+	}
+
+	if len(jwt.audience) > 0 && !containsAny(audienceClaim(claims), jwt.audience) {
+		return ErrInvalidClaim{Name: "aud", Err: fmt.Errorf("expected one of %v", jwt.audience)}
+	}
+
+	return nil
+}
+
+// timeClaim reads a NumericDate claim (seconds since the epoch), returning
+// false if the claim is not present
+func timeClaim(claims jwtgo.MapClaims, name string) (time.Time, bool, error) {
+	var seconds float64
+	switch v := claims[name].(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case float64:
+		seconds = v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false, ErrInvalidClaim{Name: name, Err: err}
+		}
+		seconds = f
+	default:
+		return time.Time{}, false, ErrInvalidClaim{Name: name, Err: errors.New("not a number")}
+	}
+
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+// audienceClaim reads the "aud" claim, which may be a single string or an
+// array of strings
+func audienceClaim(claims jwtgo.MapClaims) []string {
+	switch v := claims["aud"].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		aud := make([]string, 0, len(v))
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				aud = append(aud, s)
+			}
+		}
+		return aud
+	default:
+		return nil
+	}
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if v == w {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signClaims(t *testing.T, key *rsa.PrivateKey, extra jwtgo.MapClaims) string {
+	t.Helper()
+	claims := jwtgo.MapClaims{
+		"accountId":       "abc123",
+		"realUserId":      "xyz234",
+		"effectiveUserId": "xyz345",
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	signed, err := jwtgo.NewWithClaims(jwtgo.SigningMethodRS256, claims).SignedString(key)
+	require.Nil(t, err)
+	return signed
+}
+
+func Test_Validation_Issuer(t *testing.T) {
+	priKey, _ := devKeys(t)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithIssuer("identity-api"))
+	require.Nil(t, err)
+
+	_, err = decoder.Decode(signClaims(t, priKey, jwtgo.MapClaims{"iss": "identity-api"}))
+	assert.Nil(t, err)
+
+	_, err = decoder.Decode(signClaims(t, priKey, jwtgo.MapClaims{"iss": "someone-else"}))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "'iss'")
+
+	_, err = decoder.Decode(signClaims(t, priKey, nil))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "'iss'")
+}
+
+func Test_Validation_Audience(t *testing.T) {
+	priKey, _ := devKeys(t)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithAudience("murmur", "perform"))
+	require.Nil(t, err)
+
+	_, err = decoder.Decode(signClaims(t, priKey, jwtgo.MapClaims{"aud": "murmur"}))
+	assert.Nil(t, err)
+
+	_, err = decoder.Decode(signClaims(t, priKey, jwtgo.MapClaims{"aud": []string{"effectiveness", "perform"}}))
+	assert.Nil(t, err)
+
+	_, err = decoder.Decode(signClaims(t, priKey, jwtgo.MapClaims{"aud": []string{"effectiveness"}}))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "'aud'")
+}
+
+func Test_Validation_Leeway(t *testing.T) {
+	priKey, _ := devKeys(t)
+	now := time.Now()
+	cases := map[string]jwtgo.MapClaims{
+		"exp": {"exp": now.Add(-30 * time.Second).Unix()},
+		"nbf": {"nbf": now.Add(30 * time.Second).Unix()},
+		"iat": {"iat": now.Add(30 * time.Second).Unix()},
+	}
+
+	strict, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+	lenient, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithLeeway(time.Minute))
+	require.Nil(t, err)
+
+	for claim, claims := range cases {
+		t.Run(claim, func(t *testing.T) {
+			token := signClaims(t, priKey, claims)
+
+			_, err := strict.Decode(token)
+			assert.NotNil(t, err)
+			assert.Contains(t, err.Error(), "'"+claim+"'")
+
+			_, err = lenient.Decode(token)
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func Test_Validation_RequiredClaims(t *testing.T) {
+	priKey, _ := devKeys(t)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub", WithRequiredClaims("exp", "jti"))
+	require.Nil(t, err)
+
+	_, err = decoder.Decode(signClaims(t, priKey, jwtgo.MapClaims{"exp": time.Now().Add(time.Minute).Unix()}))
+	assert.NotNil(t, err)
+	assert.Equal(t, "missing jti in jwt token", err.Error())
+
+	_, err = decoder.Decode(signClaims(t, priKey, jwtgo.MapClaims{"exp": time.Now().Add(time.Minute).Unix(), "jti": "1"}))
+	assert.Nil(t, err)
+}
+
+func Test_Validation_InvalidTimeClaim(t *testing.T) {
+	priKey, _ := devKeys(t)
+	decoder, err := NewDecoderFromPath("jwt.rs256.key.development.pub")
+	require.Nil(t, err)
+
+	_, err = decoder.Decode(signClaims(t, priKey, jwtgo.MapClaims{"exp": "tomorrow"}))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "'exp'")
+}